@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/tlsconfig"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRateBurst = 20
+
+	DefaultRateLimit = 10 // requests per second per key
+)
+
+type keyContextKey struct{}
+
+// keyFromContext returns the API key that authenticated the request, if any.
+func keyFromContext(ctx context.Context) (auth.Key, bool) {
+	k, ok := ctx.Value(keyContextKey{}).(auth.Key)
+
+	return k, ok
+}
+
+// apiKey returns the key in the request's X-API-Key header or its bearer
+// token.
+func apiKey(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+
+	const bearer = "bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) > len(bearer) && strings.EqualFold(h[:len(bearer)], bearer) {
+		return strings.TrimSpace(h[len(bearer):])
+	}
+
+	return ""
+}
+
+// authMiddleware rejects requests without an active API key granting scope,
+// and requests beyond the key's rate limit.
+func authMiddleware(keys auth.Store, limiter *auth.RateLimiter, scope auth.Scope,
+	log *zap.SugaredLogger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := apiKey(r)
+			if token == "" {
+				unauthorized(w, r, "missing API key")
+				return
+			}
+			if auth.Validate(token) != nil {
+				unauthorized(w, r, "invalid API key")
+				return
+			}
+
+			k, err := keys.APIKeyByHash(r.Context(), auth.Hash(token))
+			switch {
+			case errors.Is(err, auth.ErrUnknownKey):
+				unauthorized(w, r, "invalid API key")
+				return
+			case err != nil:
+				writeError(w, r, log, err)
+				return
+			case !k.Active():
+				unauthorized(w, r, "revoked API key")
+				return
+			case !k.Allows(scope):
+				writeProblem(w, r, problemTypeForbidden, http.StatusForbidden,
+					fmt.Sprintf("API key lacks the %q scope", scope))
+				return
+			}
+
+			if wait, ok := limiter.Allow(k.ID); !ok {
+				w.Header().Set("Retry-After",
+					strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeProblem(w, r, problemTypeRateLimited,
+					http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			if s, ok := stateFromContext(r.Context()); ok {
+				s.keyID = k.ID
+				s.log = s.log.With("key_id", k.ID)
+			}
+			next.ServeHTTP(w, r.WithContext(
+				context.WithValue(r.Context(), keyContextKey{}, k)))
+		})
+	}
+}
+
+// clientCertMiddleware rejects requests from clients without a verified
+// certificate.
+func clientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tlsconfig.Verified(r.TLS) {
+			writeProblem(w, r, problemTypeUnauthorized, http.StatusUnauthorized,
+				"client certificate required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="stonks"`)
+	writeProblem(w, r, problemTypeUnauthorized, http.StatusUnauthorized,
+		detail)
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+)
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+
+	keys := newKeyStore()
+	read := keys.add(t, auth.ScopeRead)
+	admin := keys.add(t, auth.ScopeAdmin)
+	revoked := keys.add(t, auth.ScopeRead)
+	none := keys.add(t)
+	if err := keys.RevokeAPIKey(context.Background(),
+		keys.byToken[revoked].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(context.Background(), provider, log,
+		DisableInstrumentation(), Authenticate(keys), RateLimit(1000, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.srv.Handler
+
+	testCases := []struct {
+		uri    string
+		header string
+		value  string
+		status int
+	}{
+		{uri: "/v1/stocks", status: http.StatusUnauthorized},
+		{uri: "/v1/graphql?query={symbols{symbol}}", status: http.StatusUnauthorized},
+		{uri: "/v1/stocks", header: "X-API-Key", value: "blah", status: http.StatusUnauthorized},
+		{uri: "/v1/stocks", header: "X-API-Key", value: "stonks_blah", status: http.StatusUnauthorized},
+		{uri: "/v1/stocks", header: "X-API-Key", value: revoked, status: http.StatusUnauthorized},
+		{uri: "/v1/stocks", header: "X-API-Key", value: none, status: http.StatusForbidden},
+		{uri: "/v1/stocks", header: "X-API-Key", value: read, status: http.StatusOK},
+		{uri: "/v1/stocks", header: "Authorization", value: "Bearer " + read, status: http.StatusOK},
+		{uri: "/v1/stock/fb", header: "Authorization", value: "bearer " + admin, status: http.StatusOK},
+		{uri: "/v1/graphql?query={symbols{symbol}}", header: "X-API-Key", value: read, status: http.StatusOK},
+		{uri: "/v1/openapi.json", status: http.StatusOK},
+	}
+
+	for i, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		if tc.header != "" {
+			r.Header.Set(tc.header, tc.value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%d: actual code: %q; expected: %q", i,
+				http.StatusText(w.Code), http.StatusText(tc.status))
+		}
+		if tc.status == http.StatusUnauthorized &&
+			w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%d: missing WWW-Authenticate header", i)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	keys := newKeyStore()
+	first := keys.add(t, auth.ScopeRead)
+	second := keys.add(t, auth.ScopeRead)
+
+	srv, err := New(context.Background(), provider, log,
+		DisableInstrumentation(), Authenticate(keys), RateLimit(0.5, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.srv.Handler
+
+	get := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/symbols", nil)
+		r.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := get(first); w.Code != http.StatusOK {
+			t.Fatalf("%d: actual code: %q", i, http.StatusText(w.Code))
+		}
+	}
+
+	w := get(first)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("actual code: %q; expected: %q", http.StatusText(w.Code),
+			http.StatusText(http.StatusTooManyRequests))
+	}
+	if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("actual content type: %q", ct)
+	}
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry < 1 || retry > 2 {
+		t.Errorf("actual Retry-After: %q", w.Header().Get("Retry-After"))
+	}
+
+	// Each key has its own bucket.
+	if w := get(second); w.Code != http.StatusOK {
+		t.Errorf("second key: actual code: %q", http.StatusText(w.Code))
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	t.Parallel()
+
+	srv, err := New(context.Background(), provider, log,
+		DisableInstrumentation(), RequireClientCert())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.srv.Handler
+
+	verified := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{}}},
+	}
+	testCases := []struct {
+		uri    string
+		state  *tls.ConnectionState
+		status int
+	}{
+		{uri: "/v1/symbols", status: http.StatusUnauthorized},
+		{uri: "/v1/symbols", state: &tls.ConnectionState{},
+			status: http.StatusUnauthorized},
+		{uri: "/v1/symbols", state: verified, status: http.StatusOK},
+		{uri: "/v1/openapi.json", status: http.StatusOK},
+		{uri: "/healthz", status: http.StatusOK},
+		{uri: "/readyz", status: http.StatusOK},
+	}
+
+	for i, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		r.TLS = tc.state
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%d: actual code: %q; expected: %q", i,
+				http.StatusText(w.Code), http.StatusText(tc.status))
+		}
+	}
+}
+
+// keyStore is an in-memory auth.Store.
+type keyStore struct {
+	mu      sync.Mutex
+	byHash  map[string]auth.Key
+	byToken map[string]auth.Key
+}
+
+func newKeyStore() *keyStore {
+	return &keyStore{
+		byHash:  make(map[string]auth.Key),
+		byToken: make(map[string]auth.Key),
+	}
+}
+
+// add creates a key with scopes and returns its token.
+func (s *keyStore) add(t *testing.T, scopes ...auth.Scope) string {
+	t.Helper()
+
+	token, k, err := auth.Generate(t.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.Scopes = scopes
+	if err = s.CreateAPIKey(context.Background(), k, auth.Hash(token)); err != nil {
+		t.Fatal(err)
+	}
+	s.byToken[token] = k
+
+	return token
+}
+
+func (s *keyStore) APIKeyByHash(_ context.Context, hash []byte) (
+	auth.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.byHash[string(hash)]
+	if !ok {
+		return auth.Key{}, auth.ErrUnknownKey
+	}
+
+	return k, nil
+}
+
+func (s *keyStore) APIKeys(context.Context) ([]auth.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]auth.Key, 0, len(s.byHash))
+	for _, k := range s.byHash {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (s *keyStore) CreateAPIKey(_ context.Context, k auth.Key,
+	hash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byHash[string(hash)] = k
+
+	return nil
+}
+
+func (s *keyStore) RevokeAPIKey(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, k := range s.byHash {
+		if k.ID == id {
+			k.Revoked = time.Now()
+			s.byHash[hash] = k
+			return nil
+		}
+	}
+
+	return auth.ErrUnknownKey
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history/codec"
+)
+
+// cachePolicy is the Cache-Control header quote responses carry.
+type cachePolicy string
+
+func newCachePolicy(maxAge time.Duration, private bool) cachePolicy {
+	if maxAge < time.Second {
+		return "no-cache"
+	}
+
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+
+	return cachePolicy(fmt.Sprintf("%s, max-age=%d", scope,
+		int(maxAge/time.Second)))
+}
+
+// validators derive an ETag and Last-Modified time for a response from the
+// latest quote of each requested symbol, so a conditional request is answered
+// before the rest of the quotes are read. The ETag changes whenever a latest
+// quote does, be it a newer quote or a corrected price.
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+func newValidators(f codec.Format, q url.Values, symbols []string,
+	latest finance.QuoteBatch) validators {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s;%s", f, q.Encode())
+
+	var v validators
+	for _, symbol := range symbols {
+		_, _ = fmt.Fprintf(h, ";%s=", symbol)
+		for _, quote := range latest[symbol] {
+			_, _ = fmt.Fprintf(h, "%d:%v,", quote.Time.UnixNano(), quote.Price)
+			if quote.Time.After(v.lastModified) {
+				v.lastModified = quote.Time
+			}
+		}
+	}
+	// Weak, since gzip alters the bytes of an otherwise equal response.
+	v.etag = `W/"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+
+	return v
+}
+
+// writeHeaders sets the caching headers on w and reports whether the request's
+// conditional headers match, in which case it writes a 304 and the caller
+// should write nothing more.
+func (v validators) writeHeaders(w http.ResponseWriter, r *http.Request,
+	policy cachePolicy) bool {
+	h := w.Header()
+	h.Set("Cache-Control", string(policy))
+	h.Set("ETag", v.etag)
+	if !v.lastModified.IsZero() {
+		h.Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !v.notModified(r) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// notModified reports whether the client's cached copy is current. As in
+// RFC 7232, If-None-Match takes precedence over If-Modified-Since.
+func (v validators) notModified(r *http.Request) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, etag := range strings.Split(inm, ",") {
+			etag = strings.TrimSpace(etag)
+			if etag == "*" || weakMatch(etag, v.etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || v.lastModified.IsZero() {
+		return false
+	}
+
+	return !v.lastModified.Truncate(time.Second).After(ims)
+}
+
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
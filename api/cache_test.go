@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/memory"
+)
+
+func TestCachePolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		maxAge   time.Duration
+		private  bool
+		expected cachePolicy
+	}{
+		{maxAge: 0, expected: "no-cache"},
+		{maxAge: time.Minute, expected: "public, max-age=60"},
+		{maxAge: 90 * time.Second, private: true, expected: "private, max-age=90"},
+	}
+
+	for i, tc := range testCases {
+		actual := newCachePolicy(tc.maxAge, tc.private)
+		if actual != tc.expected {
+			t.Errorf("%d: actual: %q; expected: %q", i, actual, tc.expected)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	modified := time.Date(2021, 5, 7, 19, 31, 7, 500, time.UTC)
+	p := memory.New()
+	err := p.SetQuotes(ctx, []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: modified.Add(-time.Hour)},
+		{Price: 234.56, Symbol: "goog", Time: modified},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(ctx, p, log, CacheMaxAge(time.Minute),
+		DisableInstrumentation(), Symbols([]string{"fb", "goog"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.srv.Handler
+
+	get := func(uri string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, uri, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/v1/stocks", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+	if actual, expected := w.Header().Get("Last-Modified"),
+		modified.Format(http.TimeFormat); actual != expected {
+		t.Errorf("actual Last-Modified: %q; expected: %q", actual, expected)
+	}
+	if actual := w.Header().Get("Cache-Control"); actual != "public, max-age=60" {
+		t.Errorf("actual Cache-Control: %q", actual)
+	}
+
+	testCases := []struct {
+		uri      string
+		header   http.Header
+		expected int
+	}{
+		{
+			uri:      "/v1/stocks",
+			header:   http.Header{"If-None-Match": {`"abc", ` + etag}},
+			expected: http.StatusNotModified,
+		},
+		{
+			uri:      "/v1/stocks",
+			header:   http.Header{"If-None-Match": {"*"}},
+			expected: http.StatusNotModified,
+		},
+		{ // a different representation
+			uri:      "/v1/stocks?format=csv",
+			header:   http.Header{"If-None-Match": {etag}},
+			expected: http.StatusOK,
+		},
+		{ // a different symbol set
+			uri:      "/v1/stocks?symbols=fb",
+			header:   http.Header{"If-None-Match": {etag}},
+			expected: http.StatusOK,
+		},
+		{
+			uri: "/v1/stocks",
+			header: http.Header{
+				"If-Modified-Since": {modified.Format(http.TimeFormat)},
+			},
+			expected: http.StatusNotModified,
+		},
+		{
+			uri: "/v1/stocks",
+			header: http.Header{
+				"If-Modified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)},
+			},
+			expected: http.StatusOK,
+		},
+		{ // If-None-Match takes precedence
+			uri: "/v1/stocks",
+			header: http.Header{
+				"If-None-Match":     {`"abc"`},
+				"If-Modified-Since": {modified.Format(http.TimeFormat)},
+			},
+			expected: http.StatusOK,
+		},
+		{
+			uri: "/v1/stock/fb",
+			header: http.Header{
+				"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)},
+			},
+			expected: http.StatusNotModified,
+		},
+	}
+
+	for i, tc := range testCases {
+		w = get(tc.uri, tc.header)
+		if w.Code != tc.expected {
+			t.Errorf("%d: actual status: %d; expected: %d", i, w.Code,
+				tc.expected)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() > 0 {
+			t.Errorf("%d: unexpected body: %q", i, w.Body)
+		}
+	}
+
+	// A newer quote for any requested symbol invalidates the ETag.
+	err = p.SetQuotes(ctx, []finance.Quote{
+		{Price: 123.46, Symbol: "fb", Time: modified.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = get("/v1/stocks", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK {
+		t.Errorf("actual status after update: %d; expected: %d", w.Code,
+			http.StatusOK)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged after update")
+	}
+
+	// So does a corrected latest price at the same time.
+	for _, tc := range []struct {
+		uri   string
+		quote finance.Quote
+	}{
+		{
+			uri:   "/v1/stocks",
+			quote: finance.Quote{Price: 123.47, Symbol: "fb", Time: modified.Add(time.Minute)},
+		},
+		{
+			uri:   "/v1/stock/fb?last=2",
+			quote: finance.Quote{Price: 123.48, Symbol: "fb", Time: modified.Add(time.Minute)},
+		},
+	} {
+		etag = get(tc.uri, nil).Header().Get("ETag")
+		if err = p.SetQuotes(ctx, []finance.Quote{tc.quote}); err != nil {
+			t.Fatal(err)
+		}
+		w = get(tc.uri, http.Header{"If-None-Match": {etag}})
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: actual status after correction: %d; expected: %d",
+				tc.uri, w.Code, http.StatusOK)
+		}
+	}
+}
+
+// lastRecorder records the last argument of each batch read.
+type lastRecorder struct {
+	history.Provider
+	lasts []int
+}
+
+func (p *lastRecorder) GetQuotesBatch(ctx context.Context, symbols []string,
+	last int) (finance.QuoteBatch, error) {
+	p.lasts = append(p.lasts, last)
+
+	return p.Provider.GetQuotesBatch(ctx, symbols, last)
+}
+
+func TestConditionalRequestsSkipReads(t *testing.T) {
+	t.Parallel()
+
+	m := memory.New()
+	err := m.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: time.Now().UTC()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interval := time.Minute
+	p := &lastRecorder{Provider: m}
+	srv, err := New(context.Background(), p, log, DisableInstrumentation(),
+		Symbols([]string{"fb"}),
+		BuildInfo(Info{PollInterval: func() time.Duration { return interval }}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(w,
+		httptest.NewRequest(http.MethodGet, "/v1/stocks?last=10", nil))
+	if actual := w.Header().Get("Cache-Control"); actual != "public, max-age=60" {
+		t.Errorf("actual Cache-Control: %q", actual)
+	}
+
+	// A matching ETag is answered from the latest quotes alone, with the
+	// max age of the current poll interval.
+	interval = 2 * time.Minute
+	p.lasts = nil
+	r := httptest.NewRequest(http.MethodGet, "/v1/stocks?last=10", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("actual status: %d; expected: %d", w.Code,
+			http.StatusNotModified)
+	}
+	if !reflect.DeepEqual(p.lasts, []int{1}) {
+		t.Errorf("actual reads: %v; expected: [1]", p.lasts)
+	}
+	if actual := w.Header().Get("Cache-Control"); actual != "public, max-age=120" {
+		t.Errorf("actual Cache-Control: %q", actual)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cry0genic/go-stocks/history"
+	"go.uber.org/zap"
+)
+
+const (
+	problemContentType = "application/problem+json"
+
+	problemTypeForbidden        = "/problems/forbidden"
+	problemTypeInternal         = "/problems/internal-error"
+	problemTypeInvalidParameter = "/problems/invalid-parameter"
+	problemTypeMethodNotAllowed = "/problems/method-not-allowed"
+	problemTypeNotAcceptable    = "/problems/not-acceptable"
+	problemTypeNotFound         = "/problems/not-found"
+	problemTypeNotImplemented   = "/problems/not-implemented"
+	problemTypeRateLimited      = "/problems/rate-limited"
+	problemTypeUnauthorized     = "/problems/unauthorized"
+)
+
+// problem is an RFC 7807 problem details object, the body of every error
+// response.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// paramError reports an invalid query parameter.
+type paramError struct {
+	param  string
+	reason string
+}
+
+func (e paramError) Error() string {
+	return fmt.Sprintf("invalid %q parameter: %s", e.param, e.reason)
+}
+
+// writeError maps err to a problem and writes it to w. Errors without a
+// mapping are logged and reported as internal server errors without detail.
+func writeError(w http.ResponseWriter, r *http.Request, log *zap.SugaredLogger,
+	err error) {
+	var (
+		pErr  paramError
+		naErr notAcceptable
+	)
+
+	switch {
+	case errors.As(err, &pErr):
+		writeProblem(w, r, problemTypeInvalidParameter, http.StatusBadRequest,
+			pErr.Error())
+	case errors.As(err, &naErr):
+		writeProblem(w, r, problemTypeNotAcceptable, http.StatusNotAcceptable,
+			naErr.Error())
+	case errors.Is(err, history.ErrInvalidCursor):
+		writeProblem(w, r, problemTypeInvalidParameter, http.StatusBadRequest,
+			paramError{param: "cursor", reason: "malformed"}.Error())
+	case errors.Is(err, history.ErrNotFound):
+		writeProblem(w, r, problemTypeNotFound, http.StatusNotFound,
+			"no quotes found")
+	case errors.Is(err, history.ErrPagingUnsupported):
+		writeProblem(w, r, problemTypeNotImplemented,
+			http.StatusNotImplemented, "the history backend can't page quotes")
+	default:
+		contextLogger(r.Context(), log).Errorw(err.Error(), "url", r.URL.String())
+		writeProblem(w, r, problemTypeInternal,
+			http.StatusInternalServerError, "")
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, typ string,
+	status int, detail string) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem{
+		Type:      typ,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.RequestURI(),
+		RequestID: requestID(r),
+	})
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemTypeNotFound, http.StatusNotFound,
+		fmt.Sprintf("no resource at %q", r.URL.Path))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemTypeMethodNotAllowed,
+		http.StatusMethodNotAllowed,
+		fmt.Sprintf("%s not allowed on %q", r.Method, r.URL.Path))
+}
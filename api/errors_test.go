@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+)
+
+func TestErrorResponses(t *testing.T) {
+	t.Parallel()
+
+	srv, err := New(context.Background(), failingProvider{}, log,
+		DisableInstrumentation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := srv.srv.Handler
+
+	testCases := []struct {
+		handler http.Handler
+		method  string
+		uri     string
+		status  int
+		typ     string
+	}{
+		{
+			handler: router,
+			uri:     "/v1/stock/blah",
+			status:  http.StatusNotFound,
+			typ:     problemTypeNotFound,
+		},
+		{
+			handler: router,
+			uri:     "/v1/stock/fb?last=blah",
+			status:  http.StatusBadRequest,
+			typ:     problemTypeInvalidParameter,
+		},
+		{
+			handler: router,
+			uri:     "/v1/stock/fb?last=-1",
+			status:  http.StatusBadRequest,
+			typ:     problemTypeInvalidParameter,
+		},
+		{
+			handler: router,
+			uri:     "/v1/stock/fb?last=101",
+			status:  http.StatusBadRequest,
+			typ:     problemTypeInvalidParameter,
+		},
+		{
+			handler: router,
+			uri:     "/v1/stocks?last=blah",
+			status:  http.StatusBadRequest,
+			typ:     problemTypeInvalidParameter,
+		},
+		{
+			handler: router,
+			uri:     "/v1/stocks?last=1000000",
+			status:  http.StatusBadRequest,
+			typ:     problemTypeInvalidParameter,
+		},
+		{
+			handler: router,
+			uri:     "/v1/stocks?symbols=fb,,goog",
+			status:  http.StatusBadRequest,
+			typ:     problemTypeInvalidParameter,
+		},
+		{
+			handler: router,
+			uri:     "/v1/nonexistent",
+			status:  http.StatusNotFound,
+			typ:     problemTypeNotFound,
+		},
+		{
+			handler: router,
+			method:  http.MethodPost,
+			uri:     "/v1/stocks",
+			status:  http.StatusMethodNotAllowed,
+			typ:     problemTypeMethodNotAllowed,
+		},
+		{
+			handler: failing,
+			uri:     "/v1/stock/fb",
+			status:  http.StatusInternalServerError,
+			typ:     problemTypeInternal,
+		},
+		{
+			handler: failing,
+			uri:     "/v1/stocks",
+			status:  http.StatusInternalServerError,
+			typ:     problemTypeInternal,
+		},
+	}
+
+	for i, tc := range testCases {
+		method := tc.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, tc.uri, nil)
+		req.Header.Set("X-Request-ID", fmt.Sprintf("test-%d", i))
+
+		w := httptest.NewRecorder()
+		tc.handler.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%d: actual code: %q; expected: %q", i,
+				http.StatusText(w.Code), http.StatusText(tc.status))
+		}
+		if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+			t.Errorf("%d: actual content type: %q; expected: %q", i, ct,
+				problemContentType)
+		}
+
+		var p problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Errorf("%d: decoding problem: %v", i, err)
+			continue
+		}
+		if p.Type != tc.typ {
+			t.Errorf("%d: actual type: %q; expected: %q", i, p.Type, tc.typ)
+		}
+		if p.Status != tc.status {
+			t.Errorf("%d: actual status: %d; expected: %d", i, p.Status,
+				tc.status)
+		}
+		if p.Title != http.StatusText(tc.status) {
+			t.Errorf("%d: actual title: %q", i, p.Title)
+		}
+		if p.Instance != tc.uri {
+			t.Errorf("%d: actual instance: %q; expected: %q", i, p.Instance,
+				tc.uri)
+		}
+		if expected := fmt.Sprintf("test-%d", i); p.RequestID != expected {
+			t.Errorf("%d: actual request ID: %q; expected: %q", i,
+				p.RequestID, expected)
+		}
+		if tc.status == http.StatusInternalServerError && p.Detail != "" {
+			t.Errorf("%d: internal error detail leaked: %q", i, p.Detail)
+		}
+	}
+}
+
+var _ history.Provider = failingProvider{}
+
+type failingProvider struct{}
+
+func (failingProvider) GetQuotes(context.Context, string, int) (
+	[]finance.Quote, error) {
+	return nil, fmt.Errorf("database is locked")
+}
+
+func (failingProvider) GetQuotesBatch(context.Context, []string, int) (
+	finance.QuoteBatch, error) {
+	return nil, fmt.Errorf("database is locked")
+}
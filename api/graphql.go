@@ -0,0 +1,624 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"go.uber.org/zap"
+)
+
+// maxGraphQLBody is the largest GraphQL request body the API reads.
+const maxGraphQLBody = 1 << 20
+
+var (
+	errInternal = errors.New("internal error")
+
+	errWindowUnsupported = errors.New("the storage doesn't support time windows")
+
+	// errWindowTooWide stops walks that read more quotes than the complexity
+	// limit charged for.
+	errWindowTooWide = errors.New("window too wide")
+)
+
+// candle summarizes the quotes in an interval.
+type candle struct {
+	Time  time.Time `json:"time"`
+	Open  float64   `json:"open"`
+	High  float64   `json:"high"`
+	Low   float64   `json:"low"`
+	Close float64   `json:"close"`
+	Count int       `json:"count"`
+}
+
+// position is a holding valued at its symbol's latest quote. Price, Value
+// and Gain are nil if the symbol has no quotes.
+type position struct {
+	Symbol string   `json:"symbol"`
+	Shares float64  `json:"shares"`
+	Cost   float64  `json:"cost"`
+	Price  *float64 `json:"price"`
+	Value  *float64 `json:"value"`
+	Gain   *float64 `json:"gain"`
+}
+
+type portfolio struct {
+	Positions []position `json:"positions"`
+	Cost      float64    `json:"cost"`
+	Value     float64    `json:"value"`
+	Gain      float64    `json:"gain"`
+}
+
+// graphQLResolver resolves the GraphQL schema's fields from a provider.
+type graphQLResolver struct {
+	provider history.Provider
+	log      *zap.SugaredLogger
+	maxLast  int
+	symbols  *finance.Watchlist
+}
+
+func newGraphQLSchema(r *graphQLResolver) (graphql.Schema, error) {
+	window := graphql.FieldConfigArgument{
+		"last": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: 1,
+			Description:  "Return at most the last n results, newest first.",
+		},
+		"from": &graphql.ArgumentConfig{
+			Type:        graphql.DateTime,
+			Description: "Inclusive lower bound on quote times.",
+		},
+		"to": &graphql.ArgumentConfig{
+			Type:        graphql.DateTime,
+			Description: "Exclusive upper bound on quote times.",
+		},
+	}
+
+	quoteType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Quote",
+		Fields: graphql.Fields{
+			"symbol": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"price":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"time":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	candleType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Candle",
+		Fields: graphql.Fields{
+			"time":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"open":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"high":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"low":   &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"close": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	candleArgs := graphql.FieldConfigArgument{
+		"interval": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: `Candle width from 1m to 24h, e.g. "5m" or "1h".`,
+		},
+	}
+	for name, arg := range window {
+		candleArgs[name] = arg
+	}
+
+	symbolType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Symbol",
+		Fields: graphql.Fields{
+			"symbol": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+			"latest": &graphql.Field{
+				Type:    quoteType,
+				Resolve: r.latest,
+			},
+			"quotes": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(quoteType))),
+				Args:    window,
+				Resolve: r.quotes,
+			},
+			"candles": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(candleType))),
+				Args:    candleArgs,
+				Resolve: r.candles,
+			},
+		},
+	})
+
+	positionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Position",
+		Fields: graphql.Fields{
+			"symbol": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"shares": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"cost":   &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"price":  &graphql.Field{Type: graphql.Float},
+			"value":  &graphql.Field{Type: graphql.Float},
+			"gain":   &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	portfolioType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Portfolio",
+		Fields: graphql.Fields{
+			"positions": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(positionType))),
+			},
+			"cost":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"gain":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	positionInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PositionInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"symbol": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"shares": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Float),
+			},
+			"cost": &graphql.InputObjectFieldConfig{
+				Type:         graphql.Float,
+				DefaultValue: 0.0,
+				Description:  "Total cost of the shares.",
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"symbols": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(symbolType))),
+				Args: graphql.FieldConfigArgument{
+					"symbols": &graphql.ArgumentConfig{
+						Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+						Description: "Defaults to the tracked symbols.",
+					},
+				},
+				Resolve: r.symbolList,
+			},
+			"symbol": &graphql.Field{
+				Type: graphql.NewNonNull(symbolType),
+				Args: graphql.FieldConfigArgument{
+					"symbol": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					symbols, err := r.parseSymbols([]interface{}{p.Args["symbol"]})
+					if err != nil {
+						return nil, err
+					}
+					return symbols[0], nil
+				},
+			},
+			"portfolio": &graphql.Field{
+				Type: graphql.NewNonNull(portfolioType),
+				Args: graphql.FieldConfigArgument{
+					"positions": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(positionInput))),
+					},
+				},
+				Resolve: r.portfolio,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func (r *graphQLResolver) symbolList(p graphql.ResolveParams) (
+	interface{}, error) {
+	requested, ok := p.Args["symbols"].([]interface{})
+	if !ok || len(requested) == 0 {
+		return r.symbols.Symbols(), nil
+	}
+
+	return r.parseSymbols(requested)
+}
+
+func (r *graphQLResolver) latest(p graphql.ResolveParams) (interface{}, error) {
+	quotes, err := r.provider.GetQuotes(p.Context, p.Source.(string), 1)
+	switch {
+	case errors.Is(err, history.ErrNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, r.error(p.Context, err)
+	case len(quotes) == 0:
+		return nil, nil
+	}
+
+	return quotes[0], nil
+}
+
+func (r *graphQLResolver) quotes(p graphql.ResolveParams) (interface{}, error) {
+	last, f, err := r.window(p)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := r.read(p, last, f)
+	if err != nil {
+		return nil, err
+	}
+	if len(quotes) > last {
+		quotes = quotes[:last]
+	}
+
+	return quotes, nil
+}
+
+func (r *graphQLResolver) candles(p graphql.ResolveParams) (interface{}, error) {
+	last, f, err := r.window(p)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := time.ParseDuration(p.Args["interval"].(string))
+	if err != nil || interval < time.Minute || interval > 24*time.Hour {
+		return nil, fmt.Errorf("interval must be a duration from 1m to 24h")
+	}
+
+	// Without a lower bound, read the last n intervals up to the newest
+	// quote before the upper bound.
+	if f.From.IsZero() {
+		newest := f.To.Add(-time.Nanosecond)
+		if f.To.IsZero() {
+			quotes, err := r.provider.GetQuotes(p.Context, f.Symbols[0], 1)
+			switch {
+			case errors.Is(err, history.ErrNotFound), err == nil && len(quotes) == 0:
+				return []candle{}, nil
+			case err != nil:
+				return nil, r.error(p.Context, err)
+			}
+			newest = quotes[0].Time
+		}
+		f.From = newest.UTC().Truncate(interval).
+			Add(-time.Duration(last-1) * interval)
+	}
+
+	candles := make([]candle, 0, 2*last)
+	err = r.walk(p, f, func(q finance.Quote) error {
+		start := q.Time.UTC().Truncate(interval)
+		if n := len(candles); n > 0 && candles[n-1].Time.Equal(start) {
+			c := &candles[n-1]
+			if q.Price > c.High {
+				c.High = q.Price
+			}
+			if q.Price < c.Low {
+				c.Low = q.Price
+			}
+			c.Close = q.Price
+			c.Count++
+			return nil
+		}
+
+		// Only the newest n candles are returned.
+		if len(candles) == cap(candles) {
+			candles = append(candles[:0], candles[len(candles)-last+1:]...)
+		}
+		candles = append(candles, candle{
+			Time:  start,
+			Open:  q.Price,
+			High:  q.Price,
+			Low:   q.Price,
+			Close: q.Price,
+			Count: 1,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candles) > last {
+		candles = candles[len(candles)-last:]
+	}
+	// Newest first, like quotes.
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+
+	return candles, nil
+}
+
+func (r *graphQLResolver) portfolio(p graphql.ResolveParams) (
+	interface{}, error) {
+	inputs, _ := p.Args["positions"].([]interface{})
+	if len(inputs) == 0 {
+		return portfolio{Positions: []position{}}, nil
+	}
+
+	var (
+		positions = make([]position, 0, len(inputs))
+		symbols   = make([]interface{}, 0, len(inputs))
+	)
+	for _, input := range inputs {
+		in := input.(map[string]interface{})
+		symbols = append(symbols, in["symbol"])
+		shares, _ := in["shares"].(float64)
+		cost, _ := in["cost"].(float64)
+		positions = append(positions, position{Shares: shares, Cost: cost})
+	}
+
+	parsed, err := r.parseSymbolList(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	unique := make([]string, 0, len(parsed))
+	seen := make(map[string]struct{})
+	for _, symbol := range parsed {
+		if _, ok := seen[symbol]; !ok {
+			seen[symbol] = struct{}{}
+			unique = append(unique, symbol)
+		}
+	}
+
+	batch, err := r.provider.GetQuotesBatch(p.Context, unique, 1)
+	if err != nil && !errors.Is(err, history.ErrNotFound) {
+		return nil, r.error(p.Context, err)
+	}
+
+	var pf portfolio
+	for i := range positions {
+		pos := &positions[i]
+		pos.Symbol = parsed[i]
+		pf.Cost += pos.Cost
+
+		quotes := batch[pos.Symbol]
+		if len(quotes) == 0 {
+			continue
+		}
+		price := quotes[0].Price
+		value := price * pos.Shares
+		gain := value - pos.Cost
+		pos.Price, pos.Value, pos.Gain = &price, &value, &gain
+		pf.Value += value
+	}
+	pf.Positions = positions
+	pf.Gain = pf.Value - pf.Cost
+
+	return pf, nil
+}
+
+// window returns the field's "last" argument and a filter from its "from"
+// and "to" arguments.
+func (r *graphQLResolver) window(p graphql.ResolveParams) (
+	int, history.Filter, error) {
+	f := history.Filter{Symbols: []string{p.Source.(string)}}
+
+	last, _ := p.Args["last"].(int)
+	if last < 1 || last > r.maxLast {
+		return 0, f, fmt.Errorf("last must be between 1 and %d", r.maxLast)
+	}
+
+	if from, ok := p.Args["from"].(time.Time); ok {
+		f.From = from
+	}
+	if to, ok := p.Args["to"].(time.Time); ok {
+		f.To = to
+	}
+
+	return last, f, nil
+}
+
+// read returns the symbol's last n quotes within f, newest first.
+func (r *graphQLResolver) read(p graphql.ResolveParams, n int,
+	f history.Filter) ([]finance.Quote, error) {
+	if f.From.IsZero() && f.To.IsZero() {
+		quotes, err := r.provider.GetQuotes(p.Context, f.Symbols[0], n)
+		switch {
+		case errors.Is(err, history.ErrNotFound):
+			return []finance.Quote{}, nil
+		case err != nil:
+			return nil, r.error(p.Context, err)
+		}
+
+		return quotes, nil
+	}
+
+	// Keep the newest n quotes of the walk in a ring.
+	var (
+		ring  = make([]finance.Quote, 0, n)
+		start int
+	)
+	err := r.walk(p, f, func(q finance.Quote) error {
+		if len(ring) < n {
+			ring = append(ring, q)
+			return nil
+		}
+		ring[start] = q
+		start = (start + 1) % n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]finance.Quote, len(ring))
+	for i := range quotes {
+		quotes[i] = ring[(start+len(ring)-1-i)%len(ring)]
+	}
+
+	return quotes, nil
+}
+
+// walk streams the quotes within f to fn, oldest first. It refuses windows
+// holding more than maxLast quotes, the most the complexity limit charges a
+// windowed field for.
+func (r *graphQLResolver) walk(p graphql.ResolveParams, f history.Filter,
+	fn func(finance.Quote) error) error {
+	w, ok := r.provider.(history.Walker)
+	if !ok {
+		return errWindowUnsupported
+	}
+
+	var n int
+	err := w.WalkQuotes(p.Context, f, func(q finance.Quote) error {
+		if n++; n > r.maxLast {
+			return errWindowTooWide
+		}
+		return fn(q)
+	})
+	switch {
+	case errors.Is(err, errWindowTooWide):
+		return fmt.Errorf("the time window holds more than %d quotes", r.maxLast)
+	case errors.Is(err, history.ErrWalkingUnsupported):
+		return errWindowUnsupported
+	case err != nil:
+		return r.error(p.Context, err)
+	}
+
+	return nil
+}
+
+// error logs err and returns an error safe to show clients.
+func (r *graphQLResolver) error(ctx context.Context, err error) error {
+	contextLogger(ctx, r.log).Error(err)
+
+	return errInternal
+}
+
+func (r *graphQLResolver) parseSymbols(symbols []interface{}) (
+	[]string, error) {
+	parsed, err := r.parseSymbolList(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	unique := parsed[:0]
+	seen := make(map[string]struct{})
+	for _, symbol := range parsed {
+		if _, ok := seen[symbol]; !ok {
+			seen[symbol] = struct{}{}
+			unique = append(unique, symbol)
+		}
+	}
+
+	return unique, nil
+}
+
+// parseSymbolList returns the symbols in lowercase, in order, including
+// duplicates.
+func (r *graphQLResolver) parseSymbolList(symbols []interface{}) (
+	[]string, error) {
+	if len(symbols) > maxSymbols {
+		return nil, fmt.Errorf("more than %d symbols", maxSymbols)
+	}
+
+	parsed := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		symbol, _ := s.(string)
+		symbol = strings.ToLower(strings.TrimSpace(symbol))
+		if !validSymbol.MatchString(symbol) {
+			return nil, fmt.Errorf("invalid symbol %q", symbol)
+		}
+		parsed = append(parsed, symbol)
+	}
+
+	return parsed, nil
+}
+
+// graphQLRequest is a GraphQL request in a POST body or GET query.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func graphQL(schema graphql.Schema, limits graphQLLimits,
+	log *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := contextLogger(r.Context(), log)
+
+		defer func() { _ = r.Body.Close() }()
+
+		var req graphQLRequest
+		switch r.Method {
+		case http.MethodPost:
+			err := json.NewDecoder(http.MaxBytesReader(w, r.Body,
+				maxGraphQLBody)).Decode(&req)
+			if err != nil {
+				writeError(w, r, log, paramError{
+					param:  "body",
+					reason: "not a JSON GraphQL request",
+				})
+				return
+			}
+		default:
+			q := r.URL.Query()
+			req.Query = q.Get("query")
+			req.OperationName = q.Get("operationName")
+			if v := q.Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					writeError(w, r, log, paramError{
+						param:  "variables",
+						reason: "not a JSON object",
+					})
+					return
+				}
+			}
+		}
+		if req.Query == "" {
+			writeError(w, r, log, paramError{param: "query", reason: "missing"})
+			return
+		}
+
+		doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+		if err != nil {
+			writeGraphQL(w, log, http.StatusBadRequest, &graphql.Result{
+				Errors: gqlerrors.FormatErrors(err),
+			})
+			return
+		}
+
+		vr := graphql.ValidateDocument(&schema, doc, nil)
+		if !vr.IsValid {
+			writeGraphQL(w, log, http.StatusBadRequest,
+				&graphql.Result{Errors: vr.Errors})
+			return
+		}
+
+		if err = limits.check(doc, req.OperationName, req.Variables); err != nil {
+			writeGraphQL(w, log, http.StatusBadRequest, &graphql.Result{
+				Errors: gqlerrors.FormatErrors(err),
+			})
+			return
+		}
+
+		writeGraphQL(w, log, http.StatusOK, graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           doc,
+			OperationName: req.OperationName,
+			Args:          req.Variables,
+			Context:       r.Context(),
+		}))
+	}
+}
+
+func writeGraphQL(w http.ResponseWriter, log *zap.SugaredLogger, status int,
+	result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Warn(err)
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// DefaultGraphQLMaxComplexity lets a query read the default maximum
+	// number of quotes for twenty symbols, so a candle chart of the tracked
+	// symbols passes.
+	DefaultGraphQLMaxComplexity = 20 * DefaultMaxLast
+
+	DefaultGraphQLMaxDepth = 10
+)
+
+// graphQLLimits bounds the work a GraphQL query may ask of the provider.
+//
+// A query's depth is the deepest nesting of its selections. Its complexity
+// estimates the quotes it reads: each field costs one, plus the quotes it
+// reads from the provider, multiplied by the number of symbols it resolves
+// for. Fields that walk a time window, bounded quotes and candles, count as
+// reading maxLast quotes, the most their resolvers walk before refusing the
+// window. Introspection fields don't touch the provider and
+// count toward neither.
+type graphQLLimits struct {
+	maxComplexity int
+	maxDepth      int
+	maxLast       int
+	symbols       *finance.Watchlist
+}
+
+// check returns an error if the operation in doc exceeds the limits. doc
+// must be valid.
+func (l graphQLLimits) check(doc *ast.Document, operation string,
+	variables map[string]interface{}) error {
+	var (
+		op        *ast.OperationDefinition
+		fragments = make(map[string]*ast.FragmentDefinition)
+	)
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			if operation == "" || (def.Name != nil && def.Name.Value == operation) {
+				op = def
+			}
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		}
+	}
+	if op == nil {
+		return nil // execution reports the missing operation
+	}
+
+	vars := make(map[string]interface{}, len(variables))
+	for _, def := range op.VariableDefinitions {
+		if def.DefaultValue != nil {
+			vars[def.Variable.Name.Value] = def.DefaultValue
+		}
+	}
+	for k, v := range variables {
+		vars[k] = v
+	}
+
+	w := graphQLWalker{fragments: fragments, limits: l, vars: vars}
+	depth, complexity := w.selections(op.SelectionSet, 1)
+	if depth > l.maxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth,
+			l.maxDepth)
+	}
+	if complexity > l.maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d",
+			complexity, l.maxComplexity)
+	}
+
+	return nil
+}
+
+type graphQLWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	limits    graphQLLimits
+	vars      map[string]interface{}
+}
+
+// selections returns the depth and complexity of set when resolved mult
+// times.
+func (w graphQLWalker) selections(set *ast.SelectionSet, mult int) (
+	int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	var depth, complexity int
+	for _, sel := range set.Selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = w.field(sel, mult)
+		case *ast.InlineFragment:
+			d, c = w.selections(sel.SelectionSet, mult)
+		case *ast.FragmentSpread:
+			if f, ok := w.fragments[sel.Name.Value]; ok {
+				d, c = w.selections(f.SelectionSet, mult)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+
+	return depth, complexity
+}
+
+func (w graphQLWalker) field(f *ast.Field, mult int) (int, int) {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+
+	var reads, fanout = 0, 1
+	switch f.Name.Value {
+	case "symbols":
+		fanout = w.listLen(f, "symbols", w.limits.symbols.Len())
+	case "latest":
+		reads = 1
+	case "quotes":
+		reads = w.intArg(f, "last", 1)
+		if w.hasArg(f, "from") || w.hasArg(f, "to") {
+			reads = w.limits.maxLast
+		}
+	case "candles":
+		reads = w.limits.maxLast
+	case "portfolio":
+		reads = w.listLen(f, "positions", 0)
+	}
+
+	d, c := w.selections(f.SelectionSet, mult*fanout)
+
+	return d + 1, mult*(1+reads) + c
+}
+
+func (w graphQLWalker) arg(f *ast.Field, name string) interface{} {
+	for _, a := range f.Arguments {
+		if a.Name.Value != name {
+			continue
+		}
+		if v, ok := a.Value.(*ast.Variable); ok {
+			return w.vars[v.Name.Value]
+		}
+		return a.Value
+	}
+
+	return nil
+}
+
+func (w graphQLWalker) hasArg(f *ast.Field, name string) bool {
+	return w.arg(f, name) != nil
+}
+
+// intArg returns the integer argument, or def if it's absent or not an
+// integer. Validation and resolvers reject out of range values.
+func (w graphQLWalker) intArg(f *ast.Field, name string, def int) int {
+	switch v := w.arg(f, name).(type) {
+	case *ast.IntValue:
+		if i, err := strconv.Atoi(v.Value); err == nil {
+			return clamp(i, w.limits.maxLast)
+		}
+	case float64:
+		return clamp(int(v), w.limits.maxLast)
+	}
+
+	return def
+}
+
+// listLen returns the length of the list argument, or def if it's absent.
+func (w graphQLWalker) listLen(f *ast.Field, name string, def int) int {
+	switch v := w.arg(f, name).(type) {
+	case *ast.ListValue:
+		return len(v.Values)
+	case []interface{}:
+		return len(v)
+	case nil:
+		return def
+	}
+
+	return 1 // a single value coerced to a list
+}
+
+func clamp(i, max int) int {
+	switch {
+	case i < 0:
+		return 0
+	case i > max:
+		return max
+	}
+
+	return i
+}
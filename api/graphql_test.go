@@ -0,0 +1,399 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/memory"
+)
+
+var epoch = time.Date(2021, 5, 7, 14, 0, 0, 0, time.UTC)
+
+func newGraphQLHandler(t *testing.T, options ...Option) http.Handler {
+	t.Helper()
+
+	m := memory.New()
+	err := m.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 10, Symbol: "fb", Time: epoch},
+		{Price: 12, Symbol: "fb", Time: epoch.Add(20 * time.Minute)},
+		{Price: 9, Symbol: "fb", Time: epoch.Add(40 * time.Minute)},
+		{Price: 11, Symbol: "fb", Time: epoch.Add(70 * time.Minute)},
+		{Price: 20, Symbol: "goog", Time: epoch},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options = append([]Option{
+		DisableInstrumentation(),
+		Symbols([]string{"fb", "goog"}),
+	}, options...)
+	srv, err := New(context.Background(), m, log, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv.srv.Handler
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, h http.Handler, query string,
+	variables map[string]interface{}) (int, graphQLResponse) {
+	t.Helper()
+
+	b, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/graphql",
+		bytes.NewReader(b)))
+
+	var resp graphQLResponse
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	return w.Code, resp
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	t.Parallel()
+
+	h := newGraphQLHandler(t)
+
+	testCases := []struct {
+		query     string
+		variables map[string]interface{}
+		expected  string
+	}{
+		{ // different depths per symbol in one request
+			query: `{
+				fb: symbol(symbol: "FB") { symbol quotes(last: 2) { price } }
+				goog: symbol(symbol: "goog") { latest { price time } }
+			}`,
+			expected: `{"fb":{"quotes":[{"price":11},{"price":9}],"symbol":"fb"},` +
+				`"goog":{"latest":{"price":20,"time":"2021-05-07T14:00:00Z"}}}`,
+		},
+		{ // tracked symbols
+			query:    `{ symbols { symbol } }`,
+			expected: `{"symbols":[{"symbol":"fb"},{"symbol":"goog"}]}`,
+		},
+		{
+			query:    `{ symbols(symbols: ["nflx", "NFLX"]) { latest { price } quotes { price } } }`,
+			expected: `{"symbols":[{"latest":null,"quotes":[]}]}`,
+		},
+		{ // from is inclusive, to is exclusive
+			query: `query ($from: DateTime, $to: DateTime) {
+				symbol(symbol: "fb") { quotes(last: 10, from: $from, to: $to) { price } }
+			}`,
+			variables: map[string]interface{}{
+				"from": "2021-05-07T14:20:00Z",
+				"to":   "2021-05-07T15:10:00Z",
+			},
+			expected: `{"symbol":{"quotes":[{"price":9},{"price":12}]}}`,
+		},
+		{
+			query: `{ symbol(symbol: "fb") {
+				candles(interval: "1h", last: 5) { time open high low close count }
+			} }`,
+			expected: `{"symbol":{"candles":[` +
+				`{"close":11,"count":1,"high":11,"low":11,"open":11,"time":"2021-05-07T15:00:00Z"},` +
+				`{"close":9,"count":3,"high":12,"low":9,"open":10,"time":"2021-05-07T14:00:00Z"}]}}`,
+		},
+		{
+			query: `{ portfolio(positions: [
+				{symbol: "fb", shares: 10, cost: 100},
+				{symbol: "goog", shares: 2},
+				{symbol: "msft", shares: 1, cost: 5}
+			]) { cost value gain positions { symbol price value gain } } }`,
+			expected: `{"portfolio":{"cost":105,"gain":45,"positions":[` +
+				`{"gain":10,"price":11,"symbol":"fb","value":110},` +
+				`{"gain":40,"price":20,"symbol":"goog","value":40},` +
+				`{"gain":null,"price":null,"symbol":"msft","value":null}],"value":150}}`,
+		},
+	}
+
+	for i, tc := range testCases {
+		code, resp := postGraphQL(t, h, tc.query, tc.variables)
+		if code != http.StatusOK {
+			t.Errorf("%d: actual code: %q", i, http.StatusText(code))
+		}
+		if len(resp.Errors) > 0 {
+			t.Errorf("%d: unexpected errors: %v", i, resp.Errors)
+			continue
+		}
+
+		var actual, expected interface{}
+		_ = json.Unmarshal(resp.Data, &actual)
+		_ = json.Unmarshal([]byte(tc.expected), &expected)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%d: actual data not equal to expected", i)
+			t.Logf("expected: %s", tc.expected)
+			t.Logf("actual:   %s", resp.Data)
+		}
+	}
+}
+
+// Time windows read the quotes within them, however far back, rather than
+// filtering the newest maxLast quotes.
+func TestGraphQLWindows(t *testing.T) {
+	t.Parallel()
+
+	h := newGraphQLHandler(t, MaxLast(4))
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query: `{ symbol(symbol: "fb") {
+				quotes(last: 2, from: "2021-05-07T14:00:00Z", to: "2021-05-07T14:30:00Z") { price }
+			} }`,
+			expected: `{"symbol":{"quotes":[{"price":12},{"price":10}]}}`,
+		},
+		{
+			query: `{ symbol(symbol: "fb") {
+				quotes(last: 1, to: "2021-05-07T15:00:00Z") { price }
+			} }`,
+			expected: `{"symbol":{"quotes":[{"price":9}]}}`,
+		},
+		{
+			query: `{ symbol(symbol: "fb") {
+				candles(interval: "1h", to: "2021-05-07T15:00:00Z") { count open close }
+			} }`,
+			expected: `{"symbol":{"candles":[{"close":9,"count":3,"open":10}]}}`,
+		},
+		{ // the newest candles of a longer window
+			query: `{ symbol(symbol: "fb") {
+				candles(interval: "20m", last: 2, from: "2021-05-07T13:00:00Z") { time count }
+			} }`,
+			expected: `{"symbol":{"candles":[` +
+				`{"count":1,"time":"2021-05-07T15:00:00Z"},` +
+				`{"count":1,"time":"2021-05-07T14:40:00Z"}]}}`,
+		},
+	}
+
+	for i, tc := range testCases {
+		code, resp := postGraphQL(t, h, tc.query, nil)
+		if code != http.StatusOK {
+			t.Errorf("%d: actual code: %q", i, http.StatusText(code))
+		}
+		if len(resp.Errors) > 0 {
+			t.Errorf("%d: unexpected errors: %v", i, resp.Errors)
+			continue
+		}
+
+		var actual, expected interface{}
+		_ = json.Unmarshal(resp.Data, &actual)
+		_ = json.Unmarshal([]byte(tc.expected), &expected)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%d: actual data not equal to expected", i)
+			t.Logf("expected: %s", tc.expected)
+			t.Logf("actual:   %s", resp.Data)
+		}
+	}
+
+	// Windows holding more than maxLast quotes are refused.
+	h = newGraphQLHandler(t, MaxLast(3))
+	for _, query := range []string{
+		`{ symbol(symbol: "fb") { quotes(from: "2021-05-07T13:00:00Z") { price } } }`,
+		`{ symbol(symbol: "fb") { candles(interval: "1h", last: 3) { open } } }`,
+	} {
+		_, resp := postGraphQL(t, h, query, nil)
+		expected := "the time window holds more than 3 quotes"
+		if len(resp.Errors) == 0 || resp.Errors[0].Message != expected {
+			t.Errorf("actual errors: %v; expected: %q", resp.Errors, expected)
+		}
+	}
+
+	// Providers that can't walk their quotes refuse windows.
+	srv, err := New(context.Background(), struct{ history.Provider }{memory.New()},
+		log, DisableInstrumentation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp := postGraphQL(t, srv.srv.Handler,
+		`{ symbol(symbol: "fb") { quotes(from: "2021-05-07T14:00:00Z") { price } } }`, nil)
+	if len(resp.Errors) == 0 ||
+		resp.Errors[0].Message != errWindowUnsupported.Error() {
+		t.Errorf("actual errors: %v; expected: %q", resp.Errors,
+			errWindowUnsupported)
+	}
+}
+
+// The complexity limit lets a candle chart of the default symbols through.
+func TestGraphQLDefaultComplexity(t *testing.T) {
+	t.Parallel()
+
+	h := newGraphQLHandler(t, Symbols(finance.DefaultSymbols))
+	code, resp := postGraphQL(t, h,
+		`{ symbols { candles(interval: "5m") { open } } }`, nil)
+	if code != http.StatusOK || len(resp.Errors) > 0 {
+		t.Errorf("actual code: %q; errors: %v", http.StatusText(code),
+			resp.Errors)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		h       = newGraphQLHandler(t, MaxLast(100))
+		limited = newGraphQLHandler(t, MaxLast(100), GraphQLMaxComplexity(100))
+		shallow = newGraphQLHandler(t, GraphQLMaxDepth(2))
+	)
+
+	testCases := []struct {
+		handler   http.Handler
+		query     string
+		variables map[string]interface{}
+		code      int
+		message   string
+	}{
+		{
+			handler: shallow,
+			query:   `{ symbol(symbol: "fb") { quotes { price } } }`,
+			code:    http.StatusBadRequest,
+			message: "depth 3 exceeds",
+		},
+		{
+			query:   `{ a: symbol(symbol: "fb") { latest } b: symbols { symbol } }`,
+			code:    http.StatusBadRequest,
+			message: "must have a sub selection",
+		},
+		{ // 2 tracked symbols reading 100 quotes each
+			handler:   limited,
+			query:     `query ($n: Int) { symbols { quotes(last: $n) { price } } }`,
+			variables: map[string]interface{}{"n": 100},
+			code:      http.StatusBadRequest,
+			message:   "complexity 205 exceeds",
+		},
+		{ // bounded windows read the largest window
+			handler: limited,
+			query:   `{ symbol(symbol: "fb") { quotes(from: "2021-05-07T14:00:00Z") { price } } }`,
+			code:    http.StatusBadRequest,
+			message: "complexity 103 exceeds",
+		},
+		{
+			handler: limited,
+			query: `fragment f on Symbol { candles(interval: "1h") { count } }
+				{ symbols(symbols: ["fb"]) { ...f } }`,
+			code:    http.StatusBadRequest,
+			message: "complexity 103 exceeds",
+		},
+		{
+			query:   `{ symbol(symbol: "fb") { quotes(last: 101) { price } } }`,
+			code:    http.StatusOK,
+			message: "last must be between 1 and 100",
+		},
+		{
+			query:   `{ symbol(symbol: "f b") { latest { price } } }`,
+			code:    http.StatusOK,
+			message: `invalid symbol "f b"`,
+		},
+		{
+			query:   `{ symbol(symbol: "fb") { candles(interval: "1s") { count } } }`,
+			code:    http.StatusOK,
+			message: "interval must be",
+		},
+		{
+			query:     `query($s: [String!]) { symbols(symbols: $s) { symbol } }`,
+			variables: map[string]interface{}{"s": manySymbols(maxSymbols + 1)},
+			code:      http.StatusOK,
+			message:   "more than 100 symbols",
+		},
+		{
+			query:   `{ symbol(symbol: "fb") { `,
+			code:    http.StatusBadRequest,
+			message: "Syntax Error",
+		},
+	}
+
+	for i, tc := range testCases {
+		handler := tc.handler
+		if handler == nil {
+			handler = h
+		}
+		code, resp := postGraphQL(t, handler, tc.query, tc.variables)
+		if code != tc.code {
+			t.Errorf("%d: actual code: %q; expected: %q", i,
+				http.StatusText(code), http.StatusText(tc.code))
+		}
+		if len(resp.Errors) == 0 {
+			t.Errorf("%d: expected an error", i)
+			continue
+		}
+		if !strings.Contains(resp.Errors[0].Message, tc.message) {
+			t.Errorf("%d: actual message: %q; expected: %q", i,
+				resp.Errors[0].Message, tc.message)
+		}
+	}
+}
+
+func TestGraphQLIntrospectionDepth(t *testing.T) {
+	t.Parallel()
+
+	code, resp := postGraphQL(t, newGraphQLHandler(t, GraphQLMaxDepth(2)),
+		`{ __schema { types { fields { type { ofType { name } } } } }
+		symbol(symbol: "fb") { symbol } }`, nil)
+	if code != http.StatusOK || len(resp.Errors) > 0 {
+		t.Errorf("introspection counted toward depth: %q %v",
+			http.StatusText(code), resp.Errors)
+	}
+}
+
+func TestGraphQLRequests(t *testing.T) {
+	t.Parallel()
+
+	h := newGraphQLHandler(t)
+
+	q := url.Values{
+		"query":     {`query ($s: String!) { symbol(symbol: $s) { latest { price } } }`},
+		"variables": {`{"s": "goog"}`},
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/v1/graphql?"+q.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("actual code: %q", http.StatusText(w.Code))
+	}
+	if expected := `{"data":{"symbol":{"latest":{"price":20}}}}`; strings.TrimSpace(
+		w.Body.String()) != expected {
+		t.Errorf("actual body: %s; expected: %s", w.Body, expected)
+	}
+
+	for i, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/graphql", nil),
+		httptest.NewRequest(http.MethodGet,
+			"/v1/graphql?query=%7Bsymbols%7Bsymbol%7D%7D&variables=blah", nil),
+		httptest.NewRequest(http.MethodPost, "/v1/graphql",
+			strings.NewReader("blah")),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%d: actual code: %q", i, http.StatusText(w.Code))
+		}
+		if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+			t.Errorf("%d: actual content type: %q", i, ct)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -13,89 +16,305 @@ import (
 	"go.uber.org/zap"
 )
 
-func stock(p history.Provider, log *zap.SugaredLogger) http.HandlerFunc {
+// validSymbol matches the symbols the router accepts in /v1/stock/{symbol}.
+var validSymbol = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// maxSymbols is the most symbols a request may name, duplicates included.
+const maxSymbols = 100
+
+// stockPage is the body of a paged quote request. Next links to the following
+// page, if any.
+type stockPage struct {
+	Quotes []finance.Quote `json:"quotes"`
+	Next   string          `json:"next,omitempty"`
+}
+
+func stock(p history.Provider, pager history.Pager, maxLast int,
+	policy func() cachePolicy, log *zap.SugaredLogger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var (
-			err  error
-			last int
-		)
+		log := contextLogger(r.Context(), log)
+
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
 
+		w.Header().Set("Vary", "Accept")
+		format, err := responseFormat(r)
+		if err != nil {
+			writeError(w, r, log, err)
+			return
+		}
+
 		vars := mux.Vars(r)
 		symbol, ok := vars["symbol"]
 		if !ok || symbol == "" {
-			log.Errorw("symbol not found in request URI!", "uri", r.RequestURI)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte("Internal server error"))
+			writeError(w, r, log, fmt.Errorf("symbol not found in request URI"))
+			return
+		}
+
+		last, err := parseLast(r, maxLast)
+		if err != nil {
+			writeError(w, r, log, err)
 			return
 		}
 
-		if l := r.URL.Query().Get("last"); l != "" {
-			last, err = strconv.Atoi(l)
+		symbol = strings.ToLower(symbol)
+		after, limit, paged, err := parsePage(r, symbol, maxLast)
+		if err != nil {
+			writeError(w, r, log, err)
+			return
+		}
+		if paged && pager == nil {
+			writeError(w, r, log, history.ErrPagingUnsupported)
+			return
+		}
+		// The latest quote is enough to answer a conditional request before
+		// reading the rest.
+		quotes, err := p.GetQuotes(r.Context(), symbol, 1)
+		if err != nil {
+			writeError(w, r, log, err)
+			return
+		}
+
+		v := newValidators(format, r.URL.Query(), []string{symbol},
+			finance.QuoteBatch{symbol: quotes})
+		if v.writeHeaders(w, r, policy()) {
+			return
+		}
+
+		if paged {
+			page, err := pager.GetQuotesPage(r.Context(), symbol, after, limit)
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				_, _ = w.Write([]byte(`Invalid "last" parameter`))
+				writeError(w, r, log, err)
 				return
 			}
-		}
 
-		quotes, err := p.GetQuotes(r.Context(), strings.ToLower(symbol), last)
-		if err != nil {
-			if err == history.ErrNotFound {
-				w.WriteHeader(http.StatusNotFound)
-				_, _ = w.Write([]byte("Not found"))
-			} else {
-				log.Error(err, zap.String("url", r.URL.String()))
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte("Internal server error"))
+			resp := stockPage{Quotes: page.Quotes}
+			if page.Next != nil {
+				resp.Next = nextPage(r, *page.Next, limit)
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`,
+					resp.Next))
 			}
+			writeQuotes(w, log, format, resp, page.Quotes)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		err = json.NewEncoder(w).Encode(quotes)
-		if err != nil {
-			log.Warn(err)
+		if last > 1 {
+			quotes, err = p.GetQuotes(r.Context(), symbol, last)
+			if err != nil {
+				writeError(w, r, log, err)
+				return
+			}
 		}
+
+		writeQuotes(w, log, format, quotes, quotes)
 	}
 }
 
-func stocks(p history.Provider, log *zap.SugaredLogger) http.HandlerFunc {
+// stocksResponse is the body of a batch quote request. NotFound lists the
+// requested symbols without quotes.
+type stocksResponse struct {
+	Quotes   finance.QuoteBatch `json:"quotes"`
+	NotFound []string           `json:"not_found,omitempty"`
+}
+
+func stocks(p history.Provider, symbols *finance.Watchlist, maxLast int,
+	policy func() cachePolicy, log *zap.SugaredLogger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var (
-			err  error
-			last int
-		)
+		log := contextLogger(r.Context(), log)
+
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
 
-		if l := r.URL.Query().Get("last"); l != "" {
-			last, err = strconv.Atoi(l)
+		w.Header().Set("Vary", "Accept")
+		format, err := responseFormat(r)
+		if err != nil {
+			writeError(w, r, log, err)
+			return
+		}
+
+		last, err := parseLast(r, maxLast)
+		if err != nil {
+			writeError(w, r, log, err)
+			return
+		}
+
+		requested := symbols.Symbols()
+		if s := r.URL.Query().Get("symbols"); s != "" {
+			requested, err = parseSymbols(s)
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				_, _ = w.Write([]byte(`Invalid "last" parameter`))
+				writeError(w, r, log, err)
 				return
 			}
 		}
 
-		batch, err := p.GetQuotesBatch(r.Context(), finance.DefaultSymbols, last)
-		if err != nil {
-			if err == history.ErrNotFound {
-				w.WriteHeader(http.StatusNotFound)
-				_, _ = w.Write([]byte("Not found"))
-			} else {
-				log.Error(err, zap.String("url", r.URL.String()))
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte("Internal server error"))
-			}
+		batch, err := p.GetQuotesBatch(r.Context(), requested, 1)
+		if err != nil && err != history.ErrNotFound {
+			writeError(w, r, log, err)
+			return
+		}
+
+		v := newValidators(format, r.URL.Query(), requested, batch)
+		if v.writeHeaders(w, r, policy()) {
 			return
 		}
 
+		if last > 1 {
+			batch, err = p.GetQuotesBatch(r.Context(), requested, last)
+			if err != nil && err != history.ErrNotFound {
+				writeError(w, r, log, err)
+				return
+			}
+		}
+
+		resp := stocksResponse{Quotes: make(finance.QuoteBatch)}
+		rows := make([][]finance.Quote, 0, len(requested))
+		for _, symbol := range requested {
+			quotes, ok := batch[symbol]
+			if !ok {
+				resp.NotFound = append(resp.NotFound, symbol)
+				continue
+			}
+			resp.Quotes[symbol] = quotes
+			rows = append(rows, quotes)
+		}
+
+		// Streamed formats have no room for not_found, so report it in a
+		// header instead.
+		if format != formatJSON && len(resp.NotFound) > 0 {
+			w.Header().Set("X-Not-Found", strings.Join(resp.NotFound, ","))
+		}
+		writeQuotes(w, log, format, resp, rows...)
+	}
+}
+
+func symbolsList(symbols *finance.Watchlist,
+	log *zap.SugaredLogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		err = json.NewEncoder(w).Encode(batch)
+		err := json.NewEncoder(w).Encode(symbols.Symbols())
 		if err != nil {
 			log.Warn(err)
 		}
 	}
 }
+
+// parseLast returns the request's "last" parameter, or 0 if absent.
+func parseLast(r *http.Request, max int) (int, error) {
+	l := r.URL.Query().Get("last")
+	if l == "" {
+		return 0, nil
+	}
+
+	last, err := strconv.Atoi(l)
+	switch {
+	case err != nil:
+		return 0, paramError{param: "last", reason: "not an integer"}
+	case last < 0 || last > max:
+		return 0, paramError{
+			param:  "last",
+			reason: fmt.Sprintf("must be between 0 and %d", max),
+		}
+	}
+
+	return last, nil
+}
+
+// parsePage returns the request's "cursor" and "limit" parameters, and
+// whether it asked for a page at all. The cursor must belong to symbol.
+func parsePage(r *http.Request, symbol string, max int) (
+	*history.Cursor, int, bool, error) {
+	q := r.URL.Query()
+	c, l := q.Get("cursor"), q.Get("limit")
+	if c == "" && l == "" {
+		return nil, 0, false, nil
+	}
+	if q.Get("last") != "" {
+		return nil, 0, false, paramError{
+			param:  "last",
+			reason: "cannot be combined with cursor or limit",
+		}
+	}
+
+	limit := DefaultPageLimit
+	if max < limit {
+		limit = max
+	}
+	if l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		switch {
+		case err != nil:
+			return nil, 0, false, paramError{
+				param:  "limit",
+				reason: "not an integer",
+			}
+		case limit < 1 || limit > max:
+			return nil, 0, false, paramError{
+				param:  "limit",
+				reason: fmt.Sprintf("must be between 1 and %d", max),
+			}
+		}
+	}
+
+	if c == "" {
+		return nil, limit, true, nil
+	}
+	cursor, err := history.ParseCursor(c)
+	if err != nil {
+		return nil, 0, false, paramError{param: "cursor", reason: "malformed"}
+	}
+	if cursor.Symbol != symbol {
+		return nil, 0, false, paramError{
+			param:  "cursor",
+			reason: fmt.Sprintf("not a cursor for %q", symbol),
+		}
+	}
+
+	return &cursor, limit, true, nil
+}
+
+// nextPage returns the relative URL of the page after cursor, keeping the
+// request's other parameters.
+func nextPage(r *http.Request, cursor history.Cursor, limit int) string {
+	q := r.URL.Query()
+	q.Set("cursor", cursor.String())
+	q.Set("limit", strconv.Itoa(limit))
+
+	return (&url.URL{Path: r.URL.Path, RawQuery: q.Encode()}).String()
+}
+
+// parseSymbols returns the unique, lowercase symbols in the comma-separated
+// list s.
+func parseSymbols(s string) ([]string, error) {
+	requested := strings.Split(s, ",")
+	if len(requested) > maxSymbols {
+		return nil, paramError{
+			param:  "symbols",
+			reason: fmt.Sprintf("more than %d symbols", maxSymbols),
+		}
+	}
+
+	var (
+		symbols []string
+		seen    = make(map[string]struct{})
+	)
+	for _, symbol := range requested {
+		symbol = strings.ToLower(strings.TrimSpace(symbol))
+		if !validSymbol.MatchString(symbol) {
+			return nil, paramError{
+				param:  "symbols",
+				reason: fmt.Sprintf("invalid symbol %q", symbol),
+			}
+		}
+		if _, ok := seen[symbol]; ok {
+			continue
+		}
+		seen[symbol] = struct{}{}
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols, nil
+}
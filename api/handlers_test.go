@@ -5,19 +5,23 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
 	"github.com/cry0genic/go-stocks/history/memory"
-	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
 var (
+	symbols  = []string{"fb", "goog", "nflx"}
 	provider = memory.New()
 	log      *zap.SugaredLogger
-	router   *mux.Router
+	router   http.Handler
 )
 
 func TestStockHandler(t *testing.T) {
@@ -79,46 +83,182 @@ func TestStocksHandler(t *testing.T) {
 	}
 
 	w = httptest.NewRecorder()
-	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/stocks?last=2", nil))
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/stocks?symbols=fb,g-o-o-g", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad 'symbols' parameter results in code: %q", http.StatusText(w.Code))
+	}
 
-	var actual finance.QuoteBatch
-	err := json.NewDecoder(w.Body).Decode(&actual)
-	if err != nil {
-		t.Fatal(err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/v1/stocks?symbols="+strings.Join(manySymbols(maxSymbols+1), ","), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("too many symbols results in code: %q", http.StatusText(w.Code))
 	}
 
-	expected := finance.QuoteBatch{
-		"fb": {
-			{Price: 123.40, Symbol: "fb"},
-			{Price: 123.42, Symbol: "fb"},
+	testCases := []struct {
+		uri      string
+		expected stocksResponse
+	}{
+		{ // tracked symbols by default
+			uri: "/v1/stocks?last=2",
+			expected: stocksResponse{
+				Quotes: finance.QuoteBatch{
+					"fb": {
+						{Price: 123.40, Symbol: "fb"},
+						{Price: 123.42, Symbol: "fb"},
+					},
+					"goog": {
+						{Price: 234.51, Symbol: "goog"},
+						{Price: 234.56, Symbol: "goog"},
+					},
+				},
+				NotFound: []string{"nflx"},
+			},
+		},
+		{
+			uri: "/v1/stocks?symbols=GOOG,msft,goog&last=1",
+			expected: stocksResponse{
+				Quotes: finance.QuoteBatch{
+					"goog": {
+						{Price: 234.51, Symbol: "goog"},
+					},
+				},
+				NotFound: []string{"msft"},
+			},
 		},
-		"goog": {
-			{Price: 234.51, Symbol: "goog"},
-			{Price: 234.56, Symbol: "goog"},
+		{
+			uri: "/v1/stocks?symbols=msft",
+			expected: stocksResponse{
+				Quotes:   finance.QuoteBatch{},
+				NotFound: []string{"msft"},
+			},
 		},
 	}
 
-	if len(actual) != len(expected) {
-		t.Error("actual quote count not equal to expected count")
-		t.Logf("expected: %#v", expected)
-		t.Logf("actual:   %#v", actual)
-		t.Skip()
-	}
+	for i, tc := range testCases {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.uri, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%d: unexpected code: %q", i, http.StatusText(w.Code))
+			continue
+		}
 
-	for symbol := range actual {
-		for i, q := range actual[symbol] {
-			if q.Price != expected[symbol][i].Price {
-				t.Errorf("actual price: %.2f; expected: %.2f", q.Price,
-					expected[symbol][i].Price)
+		var actual stocksResponse
+		err := json.NewDecoder(w.Body).Decode(&actual)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(actual.NotFound, tc.expected.NotFound) {
+			t.Errorf("%d: actual not found: %q; expected: %q", i,
+				actual.NotFound, tc.expected.NotFound)
+		}
+
+		if len(actual.Quotes) != len(tc.expected.Quotes) {
+			t.Errorf("%d: actual quote count not equal to expected count", i)
+			t.Logf("expected: %#v", tc.expected.Quotes)
+			t.Logf("actual:   %#v", actual.Quotes)
+			continue
+		}
+
+		for symbol := range actual.Quotes {
+			if len(actual.Quotes[symbol]) != len(tc.expected.Quotes[symbol]) {
+				t.Errorf("%d: actual %q quote count not equal to expected",
+					i, symbol)
+				continue
 			}
-			if q.Symbol != expected[symbol][i].Symbol {
-				t.Errorf("actual symbol: %q; expected: %q", q.Symbol,
-					expected[symbol][i].Symbol)
+			for j, q := range actual.Quotes[symbol] {
+				if q.Price != tc.expected.Quotes[symbol][j].Price {
+					t.Errorf("%d: actual price: %.2f; expected: %.2f", i,
+						q.Price, tc.expected.Quotes[symbol][j].Price)
+				}
+				if q.Symbol != tc.expected.Quotes[symbol][j].Symbol {
+					t.Errorf("%d: actual symbol: %q; expected: %q", i,
+						q.Symbol, tc.expected.Quotes[symbol][j].Symbol)
+				}
 			}
 		}
 	}
 }
 
+func TestStockPages(t *testing.T) {
+	t.Parallel()
+
+	for _, uri := range []string{
+		"/v1/stock/fb?limit=0",
+		"/v1/stock/fb?limit=101",
+		"/v1/stock/fb?limit=2&last=2",
+		"/v1/stock/fb?cursor=blah",
+		"/v1/stock/fb?cursor=" + history.Cursor{Symbol: "goog", ID: 1}.String(),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, uri, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: actual status: %d; expected: %d", uri, w.Code,
+				http.StatusBadRequest)
+		}
+	}
+
+	var (
+		actual []float64
+		next   = "/v1/stock/fb?limit=2"
+		pages  int
+	)
+	for next != "" {
+		if pages++; pages > 2 {
+			t.Fatal("too many pages")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, next, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected code: %q", next, http.StatusText(w.Code))
+		}
+
+		var page stockPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range page.Quotes {
+			actual = append(actual, q.Price)
+		}
+
+		link := w.Header().Get("Link")
+		if page.Next != "" && link != "<"+page.Next+`>; rel="next"` {
+			t.Errorf("actual Link: %q; next: %q", link, page.Next)
+		}
+		if page.Next == "" && link != "" {
+			t.Errorf("unexpected Link on last page: %q", link)
+		}
+		next = page.Next
+	}
+
+	expected := []float64{123.40, 123.42, 123.45}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual prices not equal to expected")
+		t.Logf("expected: %v", expected)
+		t.Logf("actual:   %v", actual)
+	}
+}
+
+func TestSymbolsHandler(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/symbols", nil))
+
+	var actual []string
+	err := json.NewDecoder(w.Body).Decode(&actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, symbols) {
+		t.Errorf("actual symbols: %q; expected: %q", actual, symbols)
+	}
+}
+
 func init() {
 	log = zap.NewExample().Sugar()
 	quotes := []finance.Quote{
@@ -132,5 +272,20 @@ func init() {
 	if err := provider.SetQuotes(context.Background(), quotes); err != nil {
 		log.Fatal(err)
 	}
-	router = newMux(provider, log, false)
+	srv, err := New(context.Background(), provider, log,
+		DisableInstrumentation(), MaxLast(100), Symbols(symbols))
+	if err != nil {
+		log.Fatal(err)
+	}
+	router = srv.srv.Handler
+}
+
+// manySymbols returns n distinct symbols.
+func manySymbols(n int) []string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = "s" + strconv.Itoa(i)
+	}
+
+	return symbols
 }
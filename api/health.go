@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"go.uber.org/zap"
+)
+
+// readinessTimeout bounds each readiness check.
+const readinessTimeout = 2 * time.Second
+
+// Info describes the running service at /v1/info.
+type Info struct {
+	Version string
+	Commit  string
+	Storage string
+
+	// PollInterval returns the current poll interval, if set.
+	PollInterval func() time.Duration
+}
+
+// check reports whether a dependency is ready by returning nil.
+type check func(ctx context.Context) error
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// healthz reports that the process is serving requests.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(healthResponse{Status: "ok"})
+}
+
+// readyz runs every check, responding with a 503 if any fail. Failures are
+// only logged in detail since the endpoint is unauthenticated and errors may
+// carry secrets, such as URLs with tokens.
+func readyz(checks map[string]check, log *zap.SugaredLogger) http.HandlerFunc {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthResponse{
+			Status: "ok",
+			Checks: make(map[string]string, len(checks)),
+		}
+		status := http.StatusOK
+
+		for _, name := range names {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			err := checks[name](ctx)
+			cancel()
+
+			if err != nil {
+				contextLogger(r.Context(), log).Warnf("readiness check %q: %v", name, err)
+				resp.Status = "unavailable"
+				resp.Checks[name] = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[name] = "ok"
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type infoResponse struct {
+	Version      string   `json:"version"`
+	Commit       string   `json:"commit,omitempty"`
+	Symbols      []string `json:"symbols"`
+	PollInterval string   `json:"poll_interval,omitempty"`
+	Storage      string   `json:"storage,omitempty"`
+}
+
+func info(i Info, symbols *finance.Watchlist,
+	log *zap.SugaredLogger) http.HandlerFunc {
+	version := i.Version
+	if version == "" {
+		version = "dev"
+	}
+
+	return func(w http.ResponseWriter, _ *http.Request) {
+		resp := infoResponse{
+			Version: version,
+			Commit:  i.Commit,
+			Symbols: symbols.Symbols(),
+			Storage: i.Storage,
+		}
+		if i.PollInterval != nil {
+			if d := i.PollInterval(); d > 0 {
+				resp.PollInterval = d.String()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Warn(err)
+		}
+	}
+}
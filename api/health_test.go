@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+
+	pollerErr := errors.New("no successful poll in 2m0s")
+	srv, err := New(context.Background(), provider, log,
+		Authenticate(newKeyStore()), DisableInstrumentation(),
+		ReadinessCheck("archiver", func(context.Context) error { return nil }),
+		ReadinessCheck("poller", func(context.Context) error { return pollerErr }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.srv.Handler
+
+	// Neither endpoint requires an API key.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("actual /healthz status: %d; expected: %d", w.Code,
+			http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("actual /readyz status: %d; expected: %d", w.Code,
+			http.StatusServiceUnavailable)
+	}
+
+	var actual healthResponse
+	if err = json.NewDecoder(w.Body).Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := healthResponse{
+		Status: "unavailable",
+		Checks: map[string]string{
+			"archiver": "ok",
+			"poller":   "unavailable",
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual readiness not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	t.Parallel()
+
+	srv, err := New(context.Background(), provider, log,
+		DisableInstrumentation(), Symbols([]string{"FB", "goog"}),
+		BuildInfo(Info{
+			Version:      "v1.2.3",
+			Commit:       "56e14ab",
+			PollInterval: func() time.Duration { return time.Minute },
+			Storage:      "sqlite",
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(w,
+		httptest.NewRequest(http.MethodGet, "/v1/info", nil))
+
+	var actual infoResponse
+	if err = json.NewDecoder(w.Body).Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := infoResponse{
+		Version:      "v1.2.3",
+		Commit:       "56e14ab",
+		Symbols:      []string{"fb", "goog"},
+		PollInterval: "1m0s",
+		Storage:      "sqlite",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual info not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
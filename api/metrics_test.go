@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	var handlers []http.Handler
+	// Servers sharing a registry share their metrics.
+	for i := 0; i < 2; i++ {
+		srv, err := New(context.Background(), provider, log, Registerer(reg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handlers = append(handlers, srv.srv.Handler)
+	}
+
+	for i, target := range []string{
+		"/v1/stock/fb?last=2",
+		"/v1/stock/goog",
+		"/v1/stock/blah",
+		"/v1/symbols",
+	} {
+		handlers[i%2].ServeHTTP(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	expected := `
+# HELP api_requests_total A counter for requests to the wrapped handler.
+# TYPE api_requests_total counter
+api_requests_total{code="200",method="get",route="/v1/stock/{symbol:[a-zA-Z0-9]+}"} 2
+api_requests_total{code="404",method="get",route="/v1/stock/{symbol:[a-zA-Z0-9]+}"} 1
+api_requests_total{code="200",method="get",route="/v1/symbols"} 1
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"api_requests_total")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	srv, err := New(context.Background(), provider, log,
+		Authenticate(newKeyStore()), Registerer(reg),
+		Metrics("/metrics", metrics.Handler(reg, "prom", "secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		username, password string
+		expected           int
+	}{
+		{expected: http.StatusUnauthorized},
+		{username: "prom", password: "wrong", expected: http.StatusUnauthorized},
+		{username: "prom", password: "secret", expected: http.StatusOK},
+	}
+
+	for i, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tc.username != "" {
+			r.SetBasicAuth(tc.username, tc.password)
+		}
+		w := httptest.NewRecorder()
+		srv.srv.Handler.ServeHTTP(w, r)
+		if w.Code != tc.expected {
+			t.Errorf("%d: actual status: %d; expected: %d", i, w.Code,
+				tc.expected)
+		}
+		if w.Code == http.StatusOK &&
+			!strings.Contains(w.Body.String(), "server_in_flight_requests") {
+			t.Errorf("%d: expected API metrics: %s", i, w.Body)
+		}
+	}
+}
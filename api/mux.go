@@ -2,56 +2,111 @@ package api
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/cry0genic/go-stocks/auth"
 	"github.com/cry0genic/go-stocks/history"
 	"github.com/cry0genic/go-stocks/metrics"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.uber.org/zap"
 )
 
-func newMux(provider history.Provider, log *zap.SugaredLogger,
-	instrument bool) *mux.Router {
-	log = log.Named("mux")
+func newMux(srv *Server, provider history.Provider) (*mux.Router, error) {
+	log := srv.log.Named("mux")
+
+	schema, err := newGraphQLSchema(&graphQLResolver{
+		provider: provider,
+		log:      log,
+		maxLast:  srv.maxLast,
+		symbols:  srv.symbols,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	r := mux.NewRouter().StrictSlash(true)
-	r.Use(gziphandler.GzipHandler, zapLoggerMiddleware(log))
+	r.NotFoundHandler = http.HandlerFunc(notFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
+	r.Use(gziphandler.GzipHandler, tracingMiddleware)
 
-	if instrument {
-		r.Use(metricsMiddleware)
+	if srv.instrumentation {
+		m, err := metrics.NewHTTPServer(srv.registerer)
+		if err != nil {
+			return nil, err
+		}
+		r.Use(metricsMiddleware(m))
 		log.Info("API instrumented")
 	}
 
-	s := r.Methods("GET").PathPrefix("/v1").Subrouter()
-	s.HandleFunc("/stocks", stocks(provider, log))
-	s.HandleFunc("/stock/{symbol:[a-zA-Z0-9]+}", stock(provider, log))
+	r.Methods("GET").Path("/healthz").HandlerFunc(healthz)
+	r.Methods("GET").Path("/readyz").Handler(readyz(srv.readinessChecks, log))
+	r.Methods("GET").Path("/v1/openapi.json").HandlerFunc(openAPIDocument)
+	if srv.metricsHandler != nil {
+		r.Methods("GET").Path(srv.metricsPath).Handler(srv.metricsHandler)
+	}
 
-	return r
-}
+	read := r.PathPrefix("/v1").Subrouter()
+	if srv.requireClientCert {
+		read.Use(clientCertMiddleware)
+	}
+	if srv.keys != nil {
+		limiter := srv.rateLimiter
+		if limiter == nil {
+			limiter = auth.NewRateLimiter(srv.rateLimit, srv.rateBurst)
+		}
+		read.Use(authMiddleware(srv.keys, limiter, auth.ScopeRead, log))
+		log.Info("API authentication enabled")
+	}
 
-func metricsMiddleware(next http.Handler) http.Handler {
-	return promhttp.InstrumentHandlerInFlight(metrics.ServerInFlightRequests,
-		promhttp.InstrumentHandlerDuration(metrics.ServerRequestDuration,
-			promhttp.InstrumentHandlerCounter(metrics.ServerAPIRequests,
-				promhttp.InstrumentHandlerResponseSize(metrics.ServerResponseBytes,
-					next,
-				),
-			),
-		),
+	read.Methods("GET", "POST").Path("/graphql").Handler(
+		graphQL(schema, graphQLLimits{
+			maxComplexity: srv.graphQLMaxComplexity,
+			maxDepth:      srv.graphQLMaxDepth,
+			maxLast:       srv.maxLast,
+			symbols:       srv.symbols,
+		}, log),
 	)
+
+	// Responses may be cached for the current poll interval, which config
+	// reloads change, unless a fixed max age is set.
+	policy := func() cachePolicy {
+		maxAge := srv.cacheMaxAge
+		if maxAge == 0 && srv.info.PollInterval != nil {
+			maxAge = srv.info.PollInterval()
+		}
+		return newCachePolicy(maxAge, srv.keys != nil)
+	}
+	pager, _ := provider.(history.Pager)
+	s := read.Methods("GET").Subrouter()
+	s.HandleFunc("/stocks",
+		stocks(provider, srv.symbols, srv.maxLast, policy, log))
+	s.HandleFunc("/stock/{symbol:[a-zA-Z0-9]+}",
+		stock(provider, pager, srv.maxLast, policy, log))
+	s.HandleFunc("/symbols", symbolsList(srv.symbols, log))
+	s.HandleFunc("/info", info(srv.info, srv.symbols, log))
+
+	return r, nil
 }
 
-func zapLoggerMiddleware(log *zap.SugaredLogger) func(http.Handler) http.Handler {
+// metricsMiddleware labels request metrics with the matched route template,
+// rather than the path, so symbols don't multiply the time series.
+func metricsMiddleware(m *metrics.HTTPServer) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(
-			func(w http.ResponseWriter, r *http.Request) {
-				start := time.Now()
-				next.ServeHTTP(w, r)
-				log.Debugf("%s - %s %s (%s)", r.RemoteAddr, r.Method,
-					r.URL.EscapedPath(), time.Since(start))
-			},
-		)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := prometheus.Labels{"route": routeTemplate(r)}
+			promhttp.InstrumentHandlerInFlight(m.InFlightRequests,
+				promhttp.InstrumentHandlerDuration(
+					m.RequestDuration.MustCurryWith(route),
+					promhttp.InstrumentHandlerCounter(
+						m.Requests.MustCurryWith(route),
+						promhttp.InstrumentHandlerResponseSize(
+							m.ResponseBytes.MustCurryWith(route),
+							next,
+						),
+					),
+				),
+			).ServeHTTP(w, r)
+		})
 	}
 }
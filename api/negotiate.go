@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history/codec"
+	"go.uber.org/zap"
+)
+
+// formatJSON is the default response format, alongside the codec formats.
+const formatJSON codec.Format = "json"
+
+// flushEvery is the number of rows written between flushes of streamed
+// responses.
+const flushEvery = 256
+
+// offers are the media types quote endpoints can respond with, in order of
+// preference.
+var offers = []struct {
+	mediaType   string
+	contentType string
+	format      codec.Format
+}{
+	{"application/json", "application/json; charset=utf-8", formatJSON},
+	{"text/csv", "text/csv; charset=utf-8", codec.CSV},
+	{"application/x-ndjson", "application/x-ndjson", codec.NDJSON},
+}
+
+// notAcceptable reports an Accept header no offer satisfies.
+type notAcceptable string
+
+func (e notAcceptable) Error() string {
+	return fmt.Sprintf("none of %q is available", string(e))
+}
+
+// responseFormat returns the format the request's "format" parameter names
+// or, absent that, the format its Accept header prefers.
+func responseFormat(r *http.Request) (codec.Format, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		if strings.EqualFold(f, string(formatJSON)) {
+			return formatJSON, nil
+		}
+		format, err := codec.ParseFormat(f)
+		if err != nil || format == codec.Parquet {
+			return "", paramError{
+				param:  "format",
+				reason: "must be json, csv or ndjson",
+			}
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, nil
+	}
+
+	for _, mediaRange := range parseAccept(accept) {
+		for _, offer := range offers {
+			if matchMediaRange(mediaRange, offer.mediaType) {
+				return offer.format, nil
+			}
+		}
+	}
+
+	return "", notAcceptable(accept)
+}
+
+// parseAccept returns the media ranges in an Accept header with a nonzero
+// quality, highest quality first.
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	mediaTypes := make([]string, len(ranges))
+	for i, r := range ranges {
+		mediaTypes[i] = r.mediaType
+	}
+
+	return mediaTypes
+}
+
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	return strings.HasSuffix(mediaRange, "/*") &&
+		strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+}
+
+func contentType(f codec.Format) string {
+	for _, offer := range offers {
+		if offer.format == f {
+			return offer.contentType
+		}
+	}
+
+	return ""
+}
+
+// writeQuotes writes quotes in format f. JSON responses encode v; CSV and
+// NDJSON responses stream the quotes a row at a time.
+func writeQuotes(w http.ResponseWriter, log *zap.SugaredLogger, f codec.Format,
+	v interface{}, quotes ...[]finance.Quote) {
+	w.Header().Set("Content-Type", contentType(f))
+
+	if f == formatJSON {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			log.Warn(err)
+		}
+		return
+	}
+
+	enc, err := codec.NewEncoder(w, f)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	rows := 0
+	for _, qs := range quotes {
+		for _, q := range qs {
+			if err = enc.Encode(q); err != nil {
+				log.Warn(err)
+				return
+			}
+			if rows++; flusher != nil && rows%flushEvery == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+
+	if err = enc.Close(); err != nil {
+		log.Warn(err)
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseFormat(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		uri         string
+		accept      string
+		status      int
+		contentType string
+		rows        []string
+	}{
+		{ // JSON by default
+			uri:         "/v1/stock/goog",
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			uri:         "/v1/stock/goog?last=2",
+			accept:      "text/csv",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			rows: []string{
+				"symbol,price,time",
+				"goog,234.51,",
+				"goog,234.56,",
+			},
+		},
+		{
+			uri:         "/v1/stock/goog",
+			accept:      "application/json;q=0.5, application/x-ndjson",
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson",
+			rows:        []string{`{"price":234.51,"symbol":"goog",`},
+		},
+		{ // the format parameter overrides Accept
+			uri:         "/v1/stock/goog?format=csv",
+			accept:      "application/json",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			rows:        []string{"symbol,price,time", "goog,234.51,"},
+		},
+		{
+			uri:         "/v1/stocks?symbols=goog,fb&format=ndjson",
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson",
+			rows: []string{
+				`{"price":234.51,"symbol":"goog",`,
+				`{"price":123.4,"symbol":"fb",`,
+			},
+		},
+		{
+			uri:         "/v1/stocks?symbols=fb",
+			accept:      "text/*",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			rows:        []string{"symbol,price,time", "fb,123.4,"},
+		},
+		{
+			uri:         "/v1/stock/goog",
+			accept:      "*/*",
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			uri:         "/v1/stock/goog?format=parquet",
+			status:      http.StatusBadRequest,
+			contentType: problemContentType,
+		},
+		{
+			uri:         "/v1/stocks",
+			accept:      "application/xml, text/csv;q=0",
+			status:      http.StatusNotAcceptable,
+			contentType: problemContentType,
+		},
+	}
+
+	for i, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%d: actual status: %d; expected: %d", i, w.Code,
+				tc.status)
+		}
+		if actual := w.Header().Get("Content-Type"); actual != tc.contentType {
+			t.Errorf("%d: actual content type: %q; expected: %q", i, actual,
+				tc.contentType)
+		}
+
+		if tc.rows == nil {
+			continue
+		}
+		actual := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if len(actual) != len(tc.rows) {
+			t.Errorf("%d: actual row count: %d; expected: %d", i,
+				len(actual), len(tc.rows))
+			t.Logf("expected: %q", tc.rows)
+			t.Logf("actual:   %q", actual)
+			continue
+		}
+		for j, row := range tc.rows {
+			if !strings.HasPrefix(actual[j], row) {
+				t.Errorf("%d: row %d: actual: %q; expected prefix: %q", i, j,
+					actual[j], row)
+			}
+		}
+	}
+}
+
+func TestNotFoundHeader(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/v1/stocks?symbols=fb,msft,aapl&format=csv", nil))
+
+	expected := "msft,aapl"
+	if actual := w.Header().Get("X-Not-Found"); actual != expected {
+		t.Errorf("actual: %q; expected: %q", actual, expected)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// routeVariable matches a gorilla/mux path variable and its optional pattern.
+var routeVariable = regexp.MustCompile(`\{([^:}]+)(:[^}]+)?\}`)
+
+func TestOpenAPIRoutes(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPI, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented = append(documented,
+				strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	srv, err := New(context.Background(), provider, log,
+		DisableInstrumentation())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newMux(srv, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routed []string
+	err = r.Walk(
+		func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			if route.GetHandler() == nil {
+				return nil // subrouter
+			}
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return err
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return err
+			}
+			path = routeVariable.ReplaceAllString(path, "{$1}")
+			for _, method := range methods {
+				routed = append(routed, method+" "+path)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(routed)
+
+	if !reflect.DeepEqual(routed, documented) {
+		t.Error("routes not equal to OpenAPI paths")
+		t.Logf("documented: %q", documented)
+		t.Logf("routed:     %q", routed)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("actual code: %q", http.StatusText(w.Code))
+	}
+
+	var doc map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if v := doc["openapi"]; v != "3.0.3" {
+		t.Errorf("actual openapi version: %v", v)
+	}
+}
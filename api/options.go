@@ -1,15 +1,101 @@
 package api
 
-import "time"
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type Option func(*Server)
 
+// AccessLogSampling logs the first access log entries of each level every
+// second and then every thereafter-th, or none if thereafter is zero. Every
+// request is logged if first is zero.
+func AccessLogSampling(first, thereafter int) Option {
+	return func(s *Server) {
+		if first >= 0 && thereafter >= 0 {
+			s.accessLogFirst = first
+			s.accessLogThereafter = thereafter
+		}
+	}
+}
+
+// Authenticate requires an active API key from keys on every request but
+// /v1/openapi.json.
+func Authenticate(keys auth.Store) Option {
+	return func(s *Server) {
+		s.keys = keys
+	}
+}
+
+// CacheMaxAge fixes how long clients may cache quote responses without
+// revalidating. By default, it's the Info's current poll interval, if any;
+// otherwise clients must revalidate.
+func CacheMaxAge(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.cacheMaxAge = d
+		}
+	}
+}
+
 func DisableInstrumentation() Option {
 	return func(s *Server) {
 		s.instrumentation = false
 	}
 }
 
+// Metrics serves h, typically metrics.Handler, at the path. Like the health
+// checks, it doesn't require an API key.
+func Metrics(path string, h http.Handler) Option {
+	return func(s *Server) {
+		if strings.HasPrefix(path, "/") && h != nil {
+			s.metricsPath = path
+			s.metricsHandler = h
+		}
+	}
+}
+
+// Registerer registers the API server's metrics with r, unless
+// instrumentation is disabled.
+func Registerer(r prometheus.Registerer) Option {
+	return func(s *Server) {
+		s.registerer = r
+	}
+}
+
+// GraphQLMaxComplexity sets the largest estimated number of quotes a GraphQL
+// query may read.
+func GraphQLMaxComplexity(i int) Option {
+	return func(s *Server) {
+		if i > 0 {
+			s.graphQLMaxComplexity = i
+		}
+	}
+}
+
+// GraphQLMaxDepth sets the deepest selection nesting a GraphQL query may use.
+func GraphQLMaxDepth(i int) Option {
+	return func(s *Server) {
+		if i > 0 {
+			s.graphQLMaxDepth = i
+		}
+	}
+}
+
+// BuildInfo sets the service description /v1/info returns.
+func BuildInfo(i Info) Option {
+	return func(s *Server) {
+		s.info = i
+	}
+}
+
 func IdleTimeout(d time.Duration) Option {
 	return func(s *Server) {
 		if d > 0 {
@@ -26,6 +112,70 @@ func ListenAddress(addr string) Option {
 	}
 }
 
+// Symbols sets the tracked symbols /v1/stocks returns by default and
+// /v1/symbols lists.
+func Symbols(symbols []string) Option {
+	w := finance.NewWatchlist(symbols)
+
+	return func(s *Server) {
+		if w.Len() > 0 {
+			s.symbols = w
+		}
+	}
+}
+
+// Watchlist shares the tracked symbols in w, so changes to them take effect
+// on the next request.
+func Watchlist(w *finance.Watchlist) Option {
+	return func(s *Server) {
+		if w != nil {
+			s.symbols = w
+		}
+	}
+}
+
+// MaxLast sets the largest "last" parameter the API accepts.
+func MaxLast(i int) Option {
+	return func(s *Server) {
+		if i > 0 {
+			s.maxLast = i
+		}
+	}
+}
+
+// ReadinessCheck adds a check /readyz runs under name. The check reports a
+// dependency ready by returning nil.
+func ReadinessCheck(name string, c func(context.Context) error) Option {
+	return func(s *Server) {
+		if name != "" && c != nil {
+			s.readinessChecks[name] = c
+		}
+	}
+}
+
+// RateLimit sets each API key's sustained requests per second and burst
+// size. It applies only to authenticated requests.
+func RateLimit(perSecond float64, burst int) Option {
+	return func(s *Server) {
+		if perSecond > 0 {
+			s.rateLimit = perSecond
+		}
+		if burst > 0 {
+			s.rateBurst = burst
+		}
+	}
+}
+
+// RateLimiter shares l's per-key budgets with other servers, such as the gRPC
+// server, instead of the limiter RateLimit configures.
+func RateLimiter(l *auth.RateLimiter) Option {
+	return func(s *Server) {
+		if l != nil {
+			s.rateLimiter = l
+		}
+	}
+}
+
 func ReadHeaderTimeout(d time.Duration) Option {
 	return func(s *Server) {
 		if d > 0 {
@@ -33,3 +183,20 @@ func ReadHeaderTimeout(d time.Duration) Option {
 		}
 	}
 }
+
+// RequireClientCert refuses /v1 requests, but /v1/openapi.json, from
+// clients without a verified certificate. Health checks don't need one.
+func RequireClientCert() Option {
+	return func(s *Server) {
+		s.requireClientCert = true
+	}
+}
+
+// TLSConfig sets the configuration ListenAndServeTLS uses. If c provides the
+// certificate, as tlsconfig.Reloader's does, pass ListenAndServeTLS empty
+// file names.
+func TLSConfig(c *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = c
+	}
+}
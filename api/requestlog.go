@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// validRequestID matches the incoming X-Request-ID values the API adopts.
+// Others are replaced so clients can't inject arbitrary text into logs.
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+type requestContextKey struct{}
+
+// requestState follows a request through the middleware and handlers.
+type requestState struct {
+	id      string
+	keyID   string // set once authenticated
+	traceID string // set if the request is traced
+	log     *zap.SugaredLogger
+}
+
+func stateFromContext(ctx context.Context) (*requestState, bool) {
+	s, ok := ctx.Value(requestContextKey{}).(*requestState)
+
+	return s, ok
+}
+
+// requestID returns the request's correlation ID, if any.
+func requestID(r *http.Request) string {
+	if s, ok := stateFromContext(r.Context()); ok {
+		return s.id
+	}
+
+	return ""
+}
+
+// contextLogger returns the logger scoped to the request ctx belongs to, or
+// log outside of a request.
+func contextLogger(ctx context.Context,
+	log *zap.SugaredLogger) *zap.SugaredLogger {
+	if s, ok := stateFromContext(ctx); ok {
+		return s.log
+	}
+
+	return log
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// responseRecorder captures the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// newAccessLogger returns log for access logs. If first is positive, it logs
+// the first entries of each level every second and then every thereafter-th,
+// or none if thereafter is zero.
+func newAccessLogger(log *zap.SugaredLogger, first,
+	thereafter int) *zap.Logger {
+	l := log.Desugar().Named("access")
+	if first < 1 {
+		return l
+	}
+	if thereafter < 1 {
+		// The sampler can't drop every entry after the first, but it can
+		// come close enough.
+		thereafter = math.MaxInt32
+	}
+
+	return l.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(c, time.Second, first,
+			thereafter)
+	}))
+}
+
+// requestLogMiddleware assigns each request an ID, taken from its
+// X-Request-ID header if valid, and echoes it in the response. It makes a
+// logger with the ID available to handlers and logs each response.
+func requestLogMiddleware(log *zap.SugaredLogger,
+	access *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get("X-Request-ID")
+			if !validRequestID.MatchString(id) {
+				id = newRequestID()
+			}
+			w.Header().Set("X-Request-ID", id)
+
+			state := &requestState{id: id, log: log.With("request_id", id)}
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(
+				context.WithValue(r.Context(), requestContextKey{}, state)))
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			fields := []zap.Field{
+				zap.String("request_id", id),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.EscapedPath()),
+				zap.Int("status", rec.status),
+				zap.Int64("bytes", rec.bytes),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("user_agent", r.UserAgent()),
+			}
+			if state.keyID != "" {
+				fields = append(fields, zap.String("key_id", state.keyID))
+			}
+			if state.traceID != "" {
+				fields = append(fields, zap.String("trace_id", state.traceID))
+			}
+
+			if rec.status >= http.StatusInternalServerError {
+				access.Error("request", fields...)
+				return
+			}
+			access.Info("request", fields...)
+		})
+	}
+}
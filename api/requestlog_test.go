@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	generated := regexp.MustCompile(`^[0-9a-f]{16}$`)
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{header: "8f14e45f-ceea-467f", expected: "8f14e45f-ceea-467f"},
+		{header: ""},
+		{header: "bad id\nlevel=error"},
+		{header: string(make([]byte, 129))},
+	}
+
+	for i, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/v1/blah", nil)
+		if tc.header != "" {
+			r.Header.Set("X-Request-ID", tc.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		actual := w.Header().Get("X-Request-ID")
+		switch {
+		case tc.expected != "" && actual != tc.expected:
+			t.Errorf("%d: actual: %q; expected: %q", i, actual, tc.expected)
+		case tc.expected == "" && !generated.MatchString(actual):
+			t.Errorf("%d: actual: %q; expected a generated ID", i, actual)
+		}
+
+		var p problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if p.RequestID != actual {
+			t.Errorf("%d: actual problem request ID: %q; expected: %q", i,
+				p.RequestID, actual)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	keys := newKeyStore()
+	token := keys.add(t, auth.ScopeRead)
+
+	srv, err := New(context.Background(), provider, zap.New(core).Sugar(),
+		Authenticate(keys), DisableInstrumentation())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/stock/fb?last=2", nil)
+	r.Header.Set("X-API-Key", token)
+	r.Header.Set("X-Request-ID", "abc")
+	r.Header.Set("User-Agent", "test")
+	w := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(w, r)
+
+	entries := logs.FilterMessage("request").All()
+	if len(entries) != 1 {
+		t.Fatalf("actual access log entries: %d; expected: 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	expected := map[string]interface{}{
+		"request_id": "abc",
+		"method":     http.MethodGet,
+		"path":       "/v1/stock/fb",
+		"status":     int64(http.StatusOK),
+		"bytes":      int64(w.Body.Len()),
+		"user_agent": "test",
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("actual %s: %#v; expected: %#v", k, fields[k], v)
+		}
+	}
+	if id, _ := fields["key_id"].(string); id == "" {
+		t.Error("missing key_id")
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	srv, err := New(context.Background(), provider, zap.New(core).Sugar(),
+		AccessLogSampling(2, 0), DisableInstrumentation())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		srv.srv.Handler.ServeHTTP(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/v1/symbols", nil))
+	}
+
+	if n := logs.FilterMessage("request").Len(); n != 2 {
+		t.Errorf("actual access log entries: %d; expected: 2", n)
+	}
+}
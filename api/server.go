@@ -2,10 +2,14 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"time"
 
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/finance"
 	"github.com/cry0genic/go-stocks/history"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -14,17 +18,39 @@ const (
 
 	DefaultListenAddress = ":18081"
 
+	DefaultMaxLast = 10000
+
+	DefaultPageLimit = 100
+
 	DefaultReadHeaderTimeout = 30 * time.Second
 )
 
 type Server struct {
-	ctx               context.Context
-	srv               *http.Server
-	log               *zap.SugaredLogger
-	listenAddr        string
-	idleTimeout       time.Duration
-	readHeaderTimeout time.Duration
-	instrumentation   bool
+	ctx                  context.Context
+	srv                  *http.Server
+	log                  *zap.SugaredLogger
+	accessLogFirst       int
+	accessLogThereafter  int
+	cacheMaxAge          time.Duration
+	listenAddr           string
+	idleTimeout          time.Duration
+	readHeaderTimeout    time.Duration
+	instrumentation      bool
+	graphQLMaxComplexity int
+	graphQLMaxDepth      int
+	info                 Info
+	keys                 auth.Store
+	maxLast              int
+	metricsHandler       http.Handler
+	metricsPath          string
+	rateBurst            int
+	rateLimit            float64
+	rateLimiter          *auth.RateLimiter
+	readinessChecks      map[string]check
+	requireClientCert    bool
+	registerer           prometheus.Registerer
+	symbols              *finance.Watchlist
+	tlsConfig            *tls.Config
 }
 
 func (s *Server) ListenAndServe() error {
@@ -57,12 +83,19 @@ func New(ctx context.Context, p history.Provider, log *zap.SugaredLogger,
 	options ...Option) (
 	*Server, error) {
 	s := &Server{
-		ctx:               ctx,
-		log:               log.Named("api"),
-		listenAddr:        DefaultListenAddress,
-		idleTimeout:       DefaultIdleTimeout,
-		readHeaderTimeout: DefaultReadHeaderTimeout,
-		instrumentation:   true,
+		ctx:                  ctx,
+		log:                  log.Named("api"),
+		listenAddr:           DefaultListenAddress,
+		idleTimeout:          DefaultIdleTimeout,
+		readHeaderTimeout:    DefaultReadHeaderTimeout,
+		instrumentation:      true,
+		graphQLMaxComplexity: DefaultGraphQLMaxComplexity,
+		graphQLMaxDepth:      DefaultGraphQLMaxDepth,
+		maxLast:              DefaultMaxLast,
+		rateBurst:            DefaultRateBurst,
+		rateLimit:            DefaultRateLimit,
+		readinessChecks:      make(map[string]check),
+		symbols:              finance.NewWatchlist(finance.DefaultSymbols),
 	}
 
 	for _, option := range options {
@@ -71,11 +104,20 @@ func New(ctx context.Context, p history.Provider, log *zap.SugaredLogger,
 		}
 	}
 
+	handler, err := newMux(s, p)
+	if err != nil {
+		return nil, err
+	}
+
 	s.srv = &http.Server{
 		Addr:              s.listenAddr,
 		IdleTimeout:       s.idleTimeout,
 		ReadHeaderTimeout: s.readHeaderTimeout,
-		Handler:           newMux(p, log, s.instrumentation),
+		TLSConfig:         s.tlsConfig,
+		// Outside the router so unrouted requests are logged too.
+		Handler: requestLogMiddleware(s.log,
+			newAccessLogger(s.log, s.accessLogFirst, s.accessLogThereafter),
+		)(handler),
 	}
 
 	return s, nil
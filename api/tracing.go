@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware starts a span per request, continuing the client's trace
+// if the request carries one, and names it after the matched route so spans
+// for different symbols group together. The trace ID joins the request's log
+// fields.
+func tracingMiddleware(next http.Handler) http.Handler {
+	annotate := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if s, ok := stateFromContext(r.Context()); ok {
+			span.SetAttributes(attribute.String("request_id", s.id))
+			if sc := span.SpanContext(); sc.IsValid() {
+				s.traceID = sc.TraceID().String()
+				s.log = s.log.With("trace_id", s.traceID)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+
+	return otelhttp.NewHandler(annotate, "api",
+		otelhttp.WithSpanNameFormatter(spanName))
+}
+
+func spanName(_ string, r *http.Request) string {
+	if tmpl := routeTemplate(r); tmpl != "" {
+		return r.Method + " " + tmpl
+	}
+
+	return r.Method + " " + r.URL.Path
+}
+
+// routeTemplate returns the path template of the route r matched, if any.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+
+	return ""
+}
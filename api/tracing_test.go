@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSpanName(t *testing.T) {
+	t.Parallel()
+
+	var actual string
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/stock/{symbol}", func(_ http.ResponseWriter,
+		r *http.Request) {
+		actual = spanName("api", r)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/v1/stock/fb?last=3", nil))
+	if expected := "GET /v1/stock/{symbol}"; actual != expected {
+		t.Errorf("expected: %q; actual: %q", expected, actual)
+	}
+
+	unrouted := httptest.NewRequest(http.MethodPost, "/v1/blah", nil)
+	if actual = spanName("api", unrouted); actual != "POST /v1/blah" {
+		t.Errorf("actual unrouted span name: %q", actual)
+	}
+}
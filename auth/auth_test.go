@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	token, k, err := Generate("dashboards", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Validate(token); err != nil {
+		t.Errorf("generated token is invalid: %v", err)
+	}
+	if !reflect.DeepEqual(k.Scopes, []Scope{ScopeRead}) {
+		t.Errorf("expected default read scope; actual: %q", k.Scopes)
+	}
+	if !k.Active() || k.Name != "dashboards" || len(k.ID) != 16 {
+		t.Errorf("unexpected key: %#v", k)
+	}
+
+	token2, k2, err := Generate("dashboards", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == token2 || k.ID == k2.ID {
+		t.Error("generated keys are not unique")
+	}
+	if bytes.Equal(Hash(token), Hash(token2)) {
+		t.Error("distinct tokens hash equally")
+	}
+	if !bytes.Equal(Hash(token), Hash(token)) {
+		t.Error("hash is not deterministic")
+	}
+}
+
+func TestScopes(t *testing.T) {
+	t.Parallel()
+
+	scopes, err := ParseScopes([]string{"read", " ADMIN "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scopes, []Scope{ScopeRead, ScopeAdmin}) {
+		t.Errorf("actual scopes: %q", scopes)
+	}
+
+	_, err = ParseScopes([]string{"read", "write"})
+	if !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("expected ErrUnknownScope: %v", err)
+	}
+
+	testCases := []struct {
+		scopes   []Scope
+		scope    Scope
+		expected bool
+	}{
+		{scopes: []Scope{ScopeRead}, scope: ScopeRead, expected: true},
+		{scopes: []Scope{ScopeRead}, scope: ScopeAdmin, expected: false},
+		{scopes: []Scope{ScopeAdmin}, scope: ScopeRead, expected: true},
+		{scopes: nil, scope: ScopeRead, expected: false},
+	}
+
+	for i, tc := range testCases {
+		if actual := (Key{Scopes: tc.scopes}).Allows(tc.scope); actual != tc.expected {
+			t.Errorf("%d: actual: %t; expected: %t", i, actual, tc.expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, token := range []string{"", "blah", "Bearer stonks_x"} {
+		if err := Validate(token); err != ErrInvalidKey {
+			t.Errorf("%q: expected ErrInvalidKey: %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// BasicAuth requires requests to h to present the username and password with
+// basic auth. It returns h as is if username is empty.
+func BasicAuth(h http.Handler, realm, username, password string) http.Handler {
+	if username == "" {
+		return h
+	}
+
+	// Comparing digests keeps the comparisons constant time regardless of
+	// the lengths involved.
+	user, pass := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		uh, ph := sha256.Sum256([]byte(u)), sha256.Sum256([]byte(p))
+		if !ok || subtle.ConstantTimeCompare(uh[:], user[:])&
+			subtle.ConstantTimeCompare(ph[:], pass[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized),
+				http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter holds a token bucket per API key. Servers sharing a limiter
+// share each key's budget.
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimiter returns a limiter allowing each key perSecond sustained
+// requests with bursts of up to burst requests.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Allow takes a token from the bucket of the key with the given ID. If the
+// bucket is empty, it returns false and the time until a token is available.
+func (l *RateLimiter) Allow(id string) (time.Duration, bool) {
+	l.mu.Lock()
+	lim, ok := l.limiters[id]
+	if !ok {
+		lim = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[id] = lim
+	}
+	l.mu.Unlock()
+
+	res := lim.Reserve()
+	if wait := res.Delay(); wait > 0 {
+		res.Cancel()
+		return wait, false
+	}
+
+	return 0, true
+}
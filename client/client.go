@@ -0,0 +1,229 @@
+// Package client is a Go client for the stonks v1 API described by
+// /v1/openapi.json.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+const (
+	DefaultPageLimit = 100
+
+	DefaultRetries = 2
+
+	DefaultRetryBackoff = 100 * time.Millisecond
+)
+
+var ErrInvalidBaseURL = errors.New("invalid base URL")
+
+// StocksResponse is the response to a batch quote request. NotFound lists the
+// requested symbols without quotes.
+type StocksResponse struct {
+	Quotes   finance.QuoteBatch `json:"quotes"`
+	NotFound []string           `json:"not_found,omitempty"`
+}
+
+// StockPage is a page of a symbol's quotes, newest first. Cursor is empty on
+// the last page.
+type StockPage struct {
+	Quotes []finance.Quote
+	Cursor string
+}
+
+type Client struct {
+	apiKey  string
+	base    *url.URL
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// Stock returns the last n quotes for symbol, newest first. A last of zero
+// returns the latest quote.
+func (c *Client) Stock(ctx context.Context, symbol string, last int) (
+	[]finance.Quote, error) {
+	var quotes []finance.Quote
+	err := c.get(ctx, "/v1/stock/"+url.PathEscape(symbol), lastQuery(last),
+		&quotes)
+
+	return quotes, err
+}
+
+// StockPage returns up to limit of symbol's quotes following cursor, or the
+// newest quotes if cursor is empty. A limit of zero requests
+// DefaultPageLimit quotes.
+func (c *Client) StockPage(ctx context.Context, symbol, cursor string,
+	limit int) (*StockPage, error) {
+	if limit < 1 {
+		limit = DefaultPageLimit
+	}
+	q := url.Values{"limit": {strconv.Itoa(limit)}}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+
+	var resp struct {
+		Quotes []finance.Quote `json:"quotes"`
+		Next   string          `json:"next"`
+	}
+	err := c.get(ctx, "/v1/stock/"+url.PathEscape(symbol), q, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &StockPage{Quotes: resp.Quotes}
+	if resp.Next != "" {
+		next, err := url.Parse(resp.Next)
+		if err != nil {
+			return nil, fmt.Errorf("parsing next link: %w", err)
+		}
+		page.Cursor = next.Query().Get("cursor")
+	}
+
+	return page, nil
+}
+
+// Stocks returns the last n quotes for each symbol, newest first. A last of
+// zero returns the latest quote. If symbols is empty, the server returns its
+// tracked symbols.
+func (c *Client) Stocks(ctx context.Context, symbols []string, last int) (
+	*StocksResponse, error) {
+	q := lastQuery(last)
+	if len(symbols) > 0 {
+		q.Set("symbols", strings.Join(symbols, ","))
+	}
+
+	resp := new(StocksResponse)
+	err := c.get(ctx, "/v1/stocks", q, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Symbols returns the symbols the server tracks.
+func (c *Client) Symbols(ctx context.Context) ([]string, error) {
+	var symbols []string
+	err := c.get(ctx, "/v1/symbols", nil, &symbols)
+
+	return symbols, err
+}
+
+// get requests the escaped path, relative to the base URL.
+func (c *Client) get(ctx context.Context, path string, q url.Values,
+	v interface{}) error {
+	u := *c.base
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + path
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = q.Encode()
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, retry, err := c.do(ctx, u.String(), v)
+		if !retry || attempt >= c.retries {
+			return err
+		}
+
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+// do sends a single request, decoding a successful response into v. It
+// returns true if the request may succeed on retry, along with the delay the
+// server asked for, if any.
+func (c *Client) do(ctx context.Context, u string, v interface{}) (
+	time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, ctx.Err() == nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var retryAfter time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return retryAfter, retryable(resp.StatusCode), decodeError(resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return 0, false, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return 0, false, nil
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
+}
+
+func lastQuery(last int) url.Values {
+	q := make(url.Values)
+	if last > 0 {
+		q.Set("last", strconv.Itoa(last))
+	}
+
+	return q
+}
+
+// New returns a client for the API at baseURL, e.g. "http://localhost:18081".
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, ErrInvalidBaseURL
+	}
+
+	c := &Client{
+		base:    u,
+		http:    http.DefaultClient,
+		retries: DefaultRetries,
+		backoff: DefaultRetryBackoff,
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(c)
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,290 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+var now = time.Date(2021, 5, 7, 19, 31, 5, 0, time.UTC)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	for _, u := range []string{"", "localhost:18081", "/v1", "http://"} {
+		if _, err := New(u); err != ErrInvalidBaseURL {
+			t.Errorf("%q: expected ErrInvalidBaseURL: %v", u, err)
+		}
+	}
+
+	c, err := New("http://localhost:18081", Retries(0), RetryBackoff(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.retries != 0 || c.backoff != time.Second {
+		t.Errorf("options not applied: retries %d; backoff %s", c.retries,
+			c.backoff)
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	fb := []finance.Quote{{Price: 320.125, Symbol: "fb", Time: now}}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var v interface{}
+			switch r.URL.RequestURI() {
+			case "/prefix/v1/stock/fb?last=1", "/prefix/v1/stock/brk%2Fb?last=1":
+				v = fb
+			case "/prefix/v1/stocks?last=1&symbols=fb%2Cmsft":
+				v = StocksResponse{
+					Quotes:   finance.QuoteBatch{"fb": fb},
+					NotFound: []string{"msft"},
+				}
+			case "/prefix/v1/stock/fb?limit=1":
+				v = map[string]interface{}{
+					"quotes": fb,
+					"next":   "/prefix/v1/stock/fb?cursor=abc&limit=1",
+				}
+			case "/prefix/v1/stock/fb?cursor=abc&limit=1":
+				v = map[string]interface{}{"quotes": fb}
+			case "/prefix/v1/symbols":
+				v = []string{"fb", "goog"}
+			default:
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusNotFound)
+				v = Error{
+					Type:      "/problems/not-found",
+					Title:     "Not Found",
+					Status:    http.StatusNotFound,
+					Detail:    "no quotes found",
+					RequestID: "abc",
+				}
+			}
+			_ = json.NewEncoder(w).Encode(v)
+		},
+	))
+	defer srv.Close()
+
+	c, err := New(srv.URL + "/prefix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	quotes, err := c.Stock(ctx, "fb", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quotes, fb) {
+		t.Error("actual quotes not equal to expected")
+		t.Logf("expected: %#v", fb)
+		t.Logf("actual:   %#v", quotes)
+	}
+
+	page, err := c.StockPage(ctx, "fb", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Cursor != "abc" || !reflect.DeepEqual(page.Quotes, fb) {
+		t.Errorf("actual first page: %#v", page)
+	}
+	page, err = c.StockPage(ctx, "fb", page.Cursor, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Cursor != "" {
+		t.Errorf("actual last page cursor: %q", page.Cursor)
+	}
+
+	resp, err := c.Stocks(ctx, []string{"fb", "msft"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &StocksResponse{
+		Quotes:   finance.QuoteBatch{"fb": fb},
+		NotFound: []string{"msft"},
+	}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Error("actual response not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", resp)
+	}
+
+	symbols, err := c.Symbols(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(symbols, []string{"fb", "goog"}) {
+		t.Errorf("actual symbols: %q", symbols)
+	}
+
+	_, err = c.Stock(ctx, "blah", 0)
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error: %v", err)
+	}
+	e := err.(*Error)
+	if e.Detail != "no quotes found" || e.RequestID != "abc" {
+		t.Errorf("problem details not decoded: %#v", e)
+	}
+	if !IsNotFound(fmt.Errorf("reading blah: %w", err)) {
+		t.Error("expected a wrapped not found error")
+	}
+
+	// The symbol is a single, escaped path segment.
+	quotes, err = c.Stock(ctx, "brk/b", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quotes, fb) {
+		t.Errorf("actual quotes: %#v", quotes)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		status   int
+		retries  int
+		expected int32
+	}{
+		{status: http.StatusServiceUnavailable, retries: 2, expected: 3},
+		{status: http.StatusTooManyRequests, retries: 1, expected: 2},
+		{status: http.StatusServiceUnavailable, retries: 0, expected: 1},
+		{status: http.StatusBadRequest, retries: 2, expected: 1},
+	}
+
+	for i, tc := range testCases {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tc.status)
+			},
+		))
+
+		c, err := New(srv.URL, Retries(tc.retries),
+			RetryBackoff(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Symbols(context.Background())
+		e, ok := err.(*Error)
+		if !ok || e.Status != tc.status {
+			t.Errorf("%d: expected status %d: %v", i, tc.status, err)
+		}
+		if actual := atomic.LoadInt32(&calls); actual != tc.expected {
+			t.Errorf("%d: actual requests: %d; expected: %d", i, actual,
+				tc.expected)
+		}
+		srv.Close()
+	}
+}
+
+func TestRetriesRecover(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte(`["fb"]`))
+		},
+	))
+	defer srv.Close()
+
+	c, err := New(srv.URL, RetryBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols, err := c.Symbols(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(symbols, []string{"fb"}) {
+		t.Errorf("actual symbols: %q", symbols)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		calls int32
+		first time.Time
+		wait  time.Duration
+	)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if atomic.AddInt32(&calls, 1) == 1 {
+				first = time.Now()
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			wait = time.Since(first)
+			_, _ = w.Write([]byte(`["fb"]`))
+		},
+	))
+	defer srv.Close()
+
+	c, err := New(srv.URL, APIKey("secret"), RetryBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.Symbols(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if wait < time.Second {
+		t.Errorf("retried after %s; expected at least 1s", wait)
+	}
+}
+
+func TestRetriesContext(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	))
+	defer srv.Close()
+
+	c, err := New(srv.URL, Retries(100), RetryBackoff(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = c.Symbols(ctx)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retries ignored context cancellation: %s", elapsed)
+	}
+}
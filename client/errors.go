@@ -0,0 +1,60 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Error is an error response from the API: an RFC 7807 problem details
+// object.
+type Error struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.Title)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+
+	return msg
+}
+
+// IsNotFound returns true if err is, or wraps, a 404 response from the API.
+func IsNotFound(err error) bool {
+	var e *Error
+
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// decodeError returns the error in the non-2xx response resp. Responses
+// without a problem details body are reported by status alone.
+func decodeError(resp *http.Response) error {
+	e := &Error{
+		Status: resp.StatusCode,
+		Title:  http.StatusText(resp.StatusCode),
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil || len(b) == 0 {
+		return e
+	}
+	if json.Unmarshal(b, e) != nil || e.Status == 0 {
+		e.Status = resp.StatusCode
+		e.Title = http.StatusText(resp.StatusCode)
+	}
+
+	return e
+}
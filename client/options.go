@@ -0,0 +1,45 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+type Option func(*Client)
+
+// APIKey sets the API key sent with each request.
+func APIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// HTTPClient sets the HTTP client used for requests.
+func HTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		if c != nil {
+			cl.http = c
+		}
+	}
+}
+
+// Retries sets the number of times a request is retried after a network
+// error or a 5xx or 429 response. Retries wait at least as long as the
+// response's Retry-After header asks. Zero disables retries.
+func Retries(i int) Option {
+	return func(c *Client) {
+		if i >= 0 {
+			c.retries = i
+		}
+	}
+}
+
+// RetryBackoff sets the delay before the first retry. The delay doubles with
+// each subsequent retry.
+func RetryBackoff(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.backoff = d
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var (
+	apikeyCmd = &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys",
+		Long: `Manage the API keys the API server accepts when run with --api-auth.
+
+Keys are stored hashed in the SQLite database; a key is shown only once, when
+it's created.`,
+	}
+
+	apikeyCreateCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print it",
+		Args:  cobra.NoArgs,
+		RunE:  apikeyCreateRun,
+	}
+
+	apikeyListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Args:  cobra.NoArgs,
+		RunE:  apikeyListRun,
+	}
+
+	apikeyRevokeCmd = &cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  apikeyRevokeRun,
+	}
+)
+
+func init() {
+	apikeyCreateCmd.Flags().StringP("name", "n", "", "name describing the key's owner or use")
+	apikeyCreateCmd.Flags().StringSlice("scopes", []string{string(auth.ScopeRead)}, "key scopes: read, admin")
+	_ = apikeyCreateCmd.MarkFlagRequired("name")
+
+	apikeyCmd.AddCommand(apikeyCreateCmd, apikeyListCmd, apikeyRevokeCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
+
+func apikeyCreateRun(cmd *cobra.Command, _ []string) (err error) {
+	name, _ := cmd.Flags().GetString("name")
+	names, _ := cmd.Flags().GetStringSlice("scopes")
+	scopes, err := auth.ParseScopes(names)
+	if err != nil {
+		return err
+	}
+
+	token, k, err := auth.Generate(name, scopes)
+	if err != nil {
+		return err
+	}
+
+	storage, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, storage.Close()) }()
+
+	err = storage.CreateAPIKey(cmd.Context(), k, auth.Hash(token))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(),
+		"Created API key %s. Store it now; it can't be shown again.\n", k.ID)
+	fmt.Fprintln(cmd.OutOrStdout(), token)
+
+	return nil
+}
+
+func apikeyListRun(cmd *cobra.Command, _ []string) (err error) {
+	storage, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, storage.Close()) }()
+
+	keys, err := storage.APIKeys(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tREVOKED")
+	for _, k := range keys {
+		scopes := make([]string, len(k.Scopes))
+		for i, scope := range k.Scopes {
+			scopes[i] = string(scope)
+		}
+		revoked := "-"
+		if !k.Active() {
+			revoked = k.Revoked.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name,
+			strings.Join(scopes, ","), k.Created.Format(time.RFC3339), revoked)
+	}
+
+	return w.Flush()
+}
+
+func apikeyRevokeRun(cmd *cobra.Command, args []string) (err error) {
+	storage, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, storage.Close()) }()
+
+	if err = storage.RevokeAPIKey(cmd.Context(), args[0]); err != nil {
+		return fmt.Errorf("revoking %q: %w", args[0], err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Revoked API key %s.\n", args[0])
+
+	return nil
+}
@@ -0,0 +1,384 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/poll"
+	"github.com/cry0genic/go-stocks/tlsconfig"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Long: `Inspect the configuration assembled from flags, STONKS_* environment
+variables and the --config file.`,
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:   "validate [run|serve|poll]",
+		Short: "Validate the configuration and report every problem found",
+		Long: `Validate the configuration of stonks run, or of the given command, and
+report every problem found.`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"run", "serve", "poll"},
+		RunE:      configValidateRun,
+	}
+
+	// configSettleDelay is how long the configuration file must go
+	// unchanged before it's reloaded.
+	configSettleDelay = 250 * time.Millisecond
+
+	// reloadable lists the settings applied without a restart.
+	reloadable = map[string]bool{
+		"log-level": true,
+		"poll":      true,
+		"symbols":   true,
+		"verbose":   true,
+	}
+
+	journalModes = map[string]bool{
+		"DELETE": true, "TRUNCATE": true, "PERSIST": true,
+		"MEMORY": true, "WAL": true, "OFF": true,
+	}
+)
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func configValidateRun(cmd *cobra.Command, args []string) error {
+	target := runCmd
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+		case "serve":
+			target = serveCmd
+		case "poll":
+			target = pollCmd
+		default:
+			return fmt.Errorf("unknown command %q; use run, serve or poll", args[0])
+		}
+	}
+	cmd.SilenceUsage = true
+
+	// Bind the flags of the command validated so their defaults apply.
+	if err := bindFlags(target); err != nil {
+		return err
+	}
+	if err := validateConfig(target.Flags()); err != nil {
+		for _, e := range multierr.Errors(err) {
+			fmt.Fprintln(cmd.ErrOrStderr(), e)
+		}
+		return fmt.Errorf("invalid configuration")
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "configuration is valid")
+
+	return nil
+}
+
+// readConfig reads the --config file, if any. Viper picks the format from
+// the file's extension.
+func readConfig() error {
+	file := viper.GetString("config")
+	if file == "" {
+		return nil
+	}
+	viper.SetConfigFile(file)
+
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("reading configuration file: %w", err)
+	}
+
+	return nil
+}
+
+// validateConfig returns every problem with the settings of flags, combined.
+// It skips settings without a flag in flags, which their command ignores.
+func validateConfig(flags *pflag.FlagSet) (err error) {
+	check := func(key string, ok bool, format string, a ...interface{}) {
+		if !ok && flags.Lookup(key) != nil {
+			err = multierr.Append(err,
+				fmt.Errorf("%s: %s", key, fmt.Sprintf(format, a...)))
+		}
+	}
+
+	check("iex-token", viper.GetString("iex-token") != "", "IEX Cloud API token not set")
+	check("symbols", len(viper.GetStringSlice("symbols")) > 0, "no symbols to poll")
+
+	for _, key := range []string{
+		"api-idle-timeout", "api-read-headers-timeout", "buffer-flush-interval",
+		"grpc-stream-interval", "iex-call-timeout", "poll",
+	} {
+		d, dErr := cast.ToDurationE(viper.Get(key))
+		check(key, dErr == nil, "invalid duration %q", viper.GetString(key))
+		check(key, dErr != nil || d > 0, "must be positive, not %s", d)
+	}
+	busy, dErr := cast.ToDurationE(viper.Get("sqlite-busy-timeout"))
+	check("sqlite-busy-timeout", dErr == nil, "invalid duration %q", viper.GetString("sqlite-busy-timeout"))
+	check("sqlite-busy-timeout", dErr != nil || busy >= 0, "must not be negative, not %s", busy)
+	_, dErr = cast.ToDurationE(viper.Get("sqlite-conn-max-lifetime"))
+	check("sqlite-conn-max-lifetime", dErr == nil, "invalid duration %q", viper.GetString("sqlite-conn-max-lifetime"))
+
+	for _, key := range []string{
+		"api-graphql-max-complexity", "api-graphql-max-depth", "api-max-last",
+		"api-rate-burst", "buffer-flush-size", "buffer-max-size",
+		"cache-depth", "log-max-size",
+	} {
+		i, iErr := cast.ToIntE(viper.Get(key))
+		check(key, iErr == nil, "invalid integer %q", viper.GetString(key))
+		check(key, iErr != nil || i > 0, "must be positive, not %d", i)
+	}
+	for _, key := range []string{
+		"api-access-log-sample-first", "api-access-log-sample-thereafter",
+		"buffer-max-retries", "log-max-age", "log-max-backups", "sqlite-max-idle-conn",
+	} {
+		i, iErr := cast.ToIntE(viper.Get(key))
+		check(key, iErr == nil, "invalid integer %q", viper.GetString(key))
+		check(key, iErr != nil || i >= 0, "must not be negative, not %d", i)
+	}
+
+	rate, fErr := cast.ToFloat64E(viper.Get("api-rate-limit"))
+	check("api-rate-limit", fErr == nil, "invalid number %q", viper.GetString("api-rate-limit"))
+	check("api-rate-limit", fErr != nil || rate > 0, "must be positive, not %v", rate)
+	ratio, fErr := cast.ToFloat64E(viper.Get("tracing-sample-ratio"))
+	check("tracing-sample-ratio", fErr == nil, "invalid number %q", viper.GetString("tracing-sample-ratio"))
+	check("tracing-sample-ratio", fErr != nil || (ratio >= 0 && ratio <= 1),
+		"must be between 0 and 1, not %v", ratio)
+
+	for key, optional := range map[string]bool{
+		"api-listen-addr":     false,
+		"grpc-listen-addr":    true,
+		"metrics-listen-addr": true,
+		"pprof-addr":          !viper.GetBool("pprof"),
+	} {
+		addr := viper.GetString(key)
+		_, _, aErr := net.SplitHostPort(addr)
+		check(key, aErr == nil || (addr == "" && optional), "invalid listen address %q", addr)
+	}
+
+	for _, key := range []string{"iex-batch-endpoint", "tracing-endpoint"} {
+		u, uErr := url.Parse(viper.GetString(key))
+		check(key, uErr == nil && (u.Scheme == "http" || u.Scheme == "https") &&
+			u.Host != "", "invalid HTTP URL %q", viper.GetString(key))
+	}
+
+	_, lErr := configuredLogLevel()
+	check("log-level", lErr == nil, "%v", lErr)
+	exporter := viper.GetString("tracing")
+	check("tracing", exporter == tracing.ExporterNone || exporter == tracing.ExporterOTLP ||
+		exporter == tracing.ExporterStdout,
+		"unknown exporter %q; use none, otlp or stdout", exporter)
+	mode := viper.GetString("sqlite-journal-mode")
+	check("sqlite-journal-mode", journalModes[strings.ToUpper(mode)], "unknown journal mode %q", mode)
+
+	check("metrics-password", viper.GetString("metrics-username") == "" ||
+		viper.GetString("metrics-password") != "",
+		"metrics basic auth password not set")
+	check("metrics-tls-key", (viper.GetString("metrics-tls-cert") == "") ==
+		(viper.GetString("metrics-tls-key") == ""),
+		"metrics TLS needs both a certificate and a private key")
+	check("api-tls-key", (viper.GetString("api-tls-cert") == "") ==
+		(viper.GetString("api-tls-key") == ""),
+		"API TLS needs both a certificate and a private key")
+	check("api-tls-client-ca", viper.GetString("api-tls-client-ca") == "" ||
+		viper.GetString("api-tls-cert") != "",
+		"client certificates need TLS; set api-tls-cert and api-tls-key")
+	_, tErr := tlsconfig.ParseVersion(viper.GetString("api-tls-min-version"))
+	check("api-tls-min-version", tErr == nil, "%v", tErr)
+	_, tErr = tlsconfig.ParseCipherSuites(viper.GetStringSlice("api-tls-cipher-suites"))
+	check("api-tls-cipher-suites", tErr == nil, "%v", tErr)
+	check("pprof-password", viper.GetString("pprof-username") == "" ||
+		viper.GetString("pprof-password") != "",
+		"pprof basic auth password not set")
+	check("metrics-path", strings.HasPrefix(viper.GetString("metrics-path"), "/"),
+		"must start with /")
+
+	return err
+}
+
+// configuredLogLevel returns the log-level setting, or the level verbose
+// implies if it's empty.
+func configuredLogLevel() (zapcore.Level, error) {
+	switch level := strings.ToLower(viper.GetString("log-level")); level {
+	case "debug", "info", "warn", "error":
+		var l zapcore.Level
+		err := l.UnmarshalText([]byte(level))
+		return l, err
+	case "":
+		if viper.GetBool("verbose") {
+			return zapcore.DebugLevel, nil
+		}
+		return zapcore.InfoLevel, nil
+	default:
+		return zapcore.InfoLevel, fmt.Errorf(
+			"unknown level %q; use debug, info, warn or error", level)
+	}
+}
+
+// reloader applies the reloadable settings to a running server. viper isn't
+// safe for concurrent use, so once the reloader is watching, no other
+// goroutine may use it.
+type reloader struct {
+	flags     *pflag.FlagSet
+	log       *zap.SugaredLogger
+	poller    *poll.Poller // nil unless polling
+	watchlist *finance.Watchlist
+	certs     *tlsconfig.Reloader    // nil unless the API server uses TLS
+	settings  map[string]interface{} // the settings last applied
+}
+
+func newReloader(flags *pflag.FlagSet, l *zap.SugaredLogger, p *poll.Poller,
+	w *finance.Watchlist, certs *tlsconfig.Reloader) *reloader {
+	return &reloader{
+		flags:     flags,
+		log:       l.Named("config"),
+		poller:    p,
+		watchlist: w,
+		certs:     certs,
+		settings:  viper.AllSettings(),
+	}
+}
+
+// watch reloads the configuration on SIGHUP and whenever the configuration
+// file changes, one reload at a time, until ctx is canceled. SIGHUP reloads
+// the API server's certificate too.
+func (r *reloader) watch(ctx context.Context, signals <-chan os.Signal) {
+	var (
+		events     <-chan fsnotify.Event
+		errs       <-chan error
+		settled    <-chan time.Time
+		configFile = viper.ConfigFileUsed()
+		realFile   string
+	)
+	if configFile != "" {
+		// Watching the directory catches editors replacing the file and
+		// Kubernetes swapping the symlinks a ConfigMap is mounted with.
+		w, err := fsnotify.NewWatcher()
+		if err == nil {
+			err = w.Add(filepath.Dir(configFile))
+		}
+		if err != nil {
+			r.log.Errorf("watching configuration file: %v; reload with SIGHUP", err)
+		} else {
+			defer func() { _ = w.Close() }()
+			events, errs = w.Events, w.Errors
+		}
+		configFile = filepath.Clean(configFile)
+		realFile, _ = filepath.EvalSymlinks(configFile)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			r.log.Errorf("watching configuration file: %v", err)
+			continue
+		case e := <-events:
+			changed := filepath.Clean(e.Name) == configFile &&
+				e.Op&(fsnotify.Write|fsnotify.Create) != 0
+			if current, _ := filepath.EvalSymlinks(configFile); current != "" &&
+				current != realFile {
+				realFile, changed = current, true
+			}
+			if changed {
+				// Files are often written in several steps; reload once
+				// the writes stop rather than read a partial file.
+				settled = time.After(configSettleDelay)
+			}
+			continue
+		case <-settled:
+			settled = nil
+			r.log.Infof("configuration file %s changed", configFile)
+		case <-signals:
+			if r.certs != nil {
+				r.log.Info("received SIGHUP; reloading certificate")
+				if err := r.certs.Reload(); err != nil {
+					r.log.Errorf("reloading certificate: %v; keeping the current one", err)
+				}
+			}
+			if configFile == "" {
+				r.log.Info("received SIGHUP; no configuration file to reload")
+				continue
+			}
+			r.log.Info("received SIGHUP; reloading configuration")
+		}
+
+		if err := viper.ReadInConfig(); err != nil {
+			r.log.Errorf("reloading configuration: %v; keeping the current settings", err)
+			continue
+		}
+		r.apply()
+	}
+}
+
+// apply validates the settings and applies the reloadable ones. It keeps the
+// current settings if any setting is invalid.
+func (r *reloader) apply() {
+	if err := validateConfig(r.flags); err != nil {
+		for _, e := range multierr.Errors(err) {
+			r.log.Errorf("invalid configuration: %v", e)
+		}
+		r.log.Error("keeping the current settings")
+		return
+	}
+
+	level, _ := configuredLogLevel()
+	logLevel.SetLevel(level)
+	r.watchlist.Set(viper.GetStringSlice("symbols"))
+	if r.poller != nil {
+		r.poller.SetSymbols(r.watchlist.Symbols()...)
+		r.poller.SetInterval(viper.GetDuration("poll"))
+	}
+
+	settings := viper.AllSettings()
+	for _, key := range changedSettings(r.settings, settings) {
+		if !reloadable[key] {
+			r.log.Warnf("%s changed; restart to apply it", key)
+		}
+	}
+	r.settings = settings
+
+	r.log.Infof("configuration reloaded: log level %s, poll interval %s, symbols %s",
+		level, viper.GetDuration("poll"), strings.Join(r.watchlist.Symbols(), ","))
+}
+
+// changedSettings returns the sorted keys whose values differ between old
+// and new.
+func changedSettings(old, new map[string]interface{}) []string {
+	var keys []string
+	for key, v := range new {
+		if !reflect.DeepEqual(old[key], v) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
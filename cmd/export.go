@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history/codec"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [FILE]",
+	Short: "Export quote history to a CSV, NDJSON or Parquet file",
+	Long: `Export quote history to a CSV, NDJSON or Parquet file, oldest first.
+
+The format defaults to the file's extension. Without a FILE, or when FILE is
+"-", quotes are written to stdout as CSV unless --format says otherwise.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: exportRun,
+}
+
+func init() {
+	exportCmd.Flags().StringP("format", "f", "", "file format: csv, ndjson or parquet")
+	exportCmd.Flags().Bool("progress", true, "report progress on stderr")
+	addFilterFlags(exportCmd)
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func exportRun(cmd *cobra.Command, args []string) (err error) {
+	path := "-"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	format, err := fileFormat(cmd, path, codec.CSV)
+	if err != nil {
+		return err
+	}
+
+	filter, err := filterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	storage, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, storage.Close()) }()
+
+	var out io.Writer = cmd.OutOrStdout()
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() { err = multierr.Append(err, f.Close()) }()
+		out = f
+	}
+
+	enc, err := codec.NewEncoder(out, format)
+	if err != nil {
+		return err
+	}
+
+	showProgress, _ := cmd.Flags().GetBool("progress")
+	p := newProgress(cmd.ErrOrStderr(), "exported", showProgress)
+
+	err = storage.WalkQuotes(cmd.Context(), filter, func(q finance.Quote) error {
+		if err := enc.Encode(q); err != nil {
+			return fmt.Errorf("encoding %v: %w", q, err)
+		}
+		p.add(1)
+
+		return nil
+	})
+	err = multierr.Append(err, enc.Close())
+	if err == nil {
+		p.report()
+	}
+
+	return err
+}
+
+// fileFormat returns the --format flag's value if set, otherwise the format
+// matching the file extension of path, or def for stdin and stdout.
+func fileFormat(cmd *cobra.Command, path string, def codec.Format) (
+	codec.Format, error) {
+	if f, _ := cmd.Flags().GetString("format"); f != "" {
+		return codec.ParseFormat(f)
+	}
+	if path == "-" {
+		return def, nil
+	}
+
+	return codec.FormatFromPath(path)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"github.com/cry0genic/go-stocks/api"
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/finance/iexcloud"
+	"github.com/cry0genic/go-stocks/history/buffer"
+	"github.com/cry0genic/go-stocks/history/cache"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/poll"
+	"github.com/cry0genic/go-stocks/profiling"
+	"github.com/cry0genic/go-stocks/rpc"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/spf13/pflag"
+)
+
+// addAPIFlags adds the flags configuring the API and gRPC servers.
+func addAPIFlags(f *pflag.FlagSet) {
+	f.Int("api-access-log-sample-first", 0, "access log entries logged each second before sampling (0 logs every request)")
+	f.Int("api-access-log-sample-thereafter", 100, "log every nth access log entry each second after the first")
+	f.Bool("api-auth", false, "require an API key (see stonks apikey) for API and gRPC requests")
+	f.Int("api-graphql-max-complexity", api.DefaultGraphQLMaxComplexity, "max estimated quotes a GraphQL query may read")
+	f.Int("api-graphql-max-depth", api.DefaultGraphQLMaxDepth, "max GraphQL query depth")
+	f.Duration("api-idle-timeout", api.DefaultIdleTimeout, "duration clients are allowed to idle")
+	f.StringP("api-listen-addr", "a", api.DefaultListenAddress, "API server host:port")
+	f.Int("api-max-last", api.DefaultMaxLast, "largest number of quotes a client may request")
+	f.Bool("api-metrics", true, "enable metrics for the API server")
+	f.Int("api-rate-burst", api.DefaultRateBurst, "requests an API key may make in a burst")
+	f.Float64("api-rate-limit", api.DefaultRateLimit, "sustained requests per second per API key")
+	f.Duration("api-read-headers-timeout", api.DefaultReadHeaderTimeout, "duration clients have to send request headers")
+	f.String("api-tls-cert", "", "API and gRPC server TLS certificate file, reloaded when it changes (empty disables TLS)")
+	f.StringSlice("api-tls-cipher-suites", nil, "TLS 1.2 cipher suites the API and gRPC servers accept (empty uses Go's defaults)")
+	f.String("api-tls-client-ca", "", "CA certificates file clients' certificates must be signed by (empty doesn't ask for one; health checks never need one)")
+	f.String("api-tls-key", "", "API and gRPC server TLS private key file")
+	f.String("api-tls-min-version", "1.2", "lowest TLS version the API and gRPC servers accept: 1.0, 1.1, 1.2 or 1.3")
+
+	f.String("grpc-listen-addr", rpc.DefaultListenAddress, "gRPC server host:port (empty disables the server)")
+	f.Bool("grpc-metrics", true, "enable metrics for the gRPC server")
+	f.Duration("grpc-stream-interval", rpc.DefaultStreamInterval, "duration between checks for newer streamed quotes")
+
+	f.Bool("metrics-on-api", false, "serve metrics on the API server instead of a separate server")
+}
+
+// addCacheFlags adds the flags configuring the quote cache, which only
+// helps when the API serves the quotes the poller archives.
+func addCacheFlags(f *pflag.FlagSet) {
+	f.Bool("cache", false, "cache the latest quotes per symbol in memory")
+	f.Int("cache-depth", cache.DefaultDepth, "quotes cached per symbol")
+}
+
+// addPollFlags adds the flags configuring the poller and the IEX Cloud
+// client it polls.
+func addPollFlags(f *pflag.FlagSet) {
+	f.Bool("buffer", false, "buffer quotes in memory and archive them in batches")
+	f.Duration("buffer-flush-interval", buffer.DefaultFlushInterval, "max duration quotes wait in the buffer")
+	f.Int("buffer-flush-size", buffer.DefaultFlushSize, "buffered quote count that triggers a flush")
+	f.Int("buffer-max-retries", buffer.DefaultMaxRetries, "failed flushes in a row before the buffer drops the quotes; 0 never drops")
+	f.Int("buffer-max-size", buffer.DefaultMaxSize, "max buffered quotes before archiving blocks")
+
+	f.String("iex-batch-endpoint", iexcloud.DefaultBatchEndpoint, "IEX Cloud API batch endpoint URL")
+	f.Duration("iex-call-timeout", iexcloud.DefaultTimeout, "API call timeout")
+	f.Bool("iex-metrics", false, "collect metrics for IEX Cloud API calls")
+	f.StringP("iex-token", "t", "", "IEX Cloud API token")
+
+	f.Bool("poll-price-changes", false, "archive a symbol's quote only when its price changes")
+}
+
+// addDaemonFlags adds the flags shared by the long-running commands:
+// logging, metrics, tracing, pprof, the poll interval and the symbols.
+func addDaemonFlags(f *pflag.FlagSet) {
+	f.StringP("log", "l", "stdout", "log file path")
+	f.Bool("log-compress", false, "compress rotated log files")
+	f.String("log-level", "", "log level: debug, info, warn or error (overrides verbose)")
+	f.Bool("log-localtime", false, "log file names use local time, UTC otherwise")
+	f.Int("log-max-age", 7, "max days to retain old log files")
+	f.Int("log-max-backups", 5, "max number of old log files to retain")
+	f.Int("log-max-size", 100, "max log file size in MB before rotation")
+
+	f.String("metrics-listen-addr", metrics.DefaultListenAddress, "metrics server host:port (empty disables the server)")
+	f.String("metrics-password", "", "metrics basic auth password")
+	f.String("metrics-path", metrics.DefaultPath, "path metrics are served on")
+	f.String("metrics-tls-cert", "", "metrics server TLS certificate file")
+	f.String("metrics-tls-key", "", "metrics server TLS private key file")
+	f.String("metrics-username", "", "metrics basic auth username (empty disables basic auth)")
+
+	f.DurationP("poll", "p", poll.DefaultPollDuration, "duration between stock quote updates")
+	f.Bool("pprof", false, "serve pprof profiles")
+	f.String("pprof-addr", profiling.DefaultListenAddress, "pprof server host:port")
+	f.String("pprof-password", "", "pprof basic auth password")
+	f.String("pprof-username", "", "pprof basic auth username (empty disables basic auth)")
+	f.StringSliceP("symbols", "s", finance.DefaultSymbols, "stock symbols")
+	f.String("tracing", tracing.ExporterNone, "trace exporter: none, otlp or stdout")
+	f.String("tracing-endpoint", tracing.DefaultEndpoint, "OTLP/HTTP collector URL")
+	f.Float64("tracing-sample-ratio", tracing.DefaultSampleRatio, "fraction of new traces to sample")
+	f.BoolP("verbose", "v", true, "verbose logging")
+}
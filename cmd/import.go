@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/codec"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+const defaultImportBatchSize = 1000
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import quote history from a CSV, NDJSON or Parquet file",
+	Long: `Import quote history from a CSV, NDJSON or Parquet file.
+
+The format defaults to the file's extension. When FILE is "-", quotes are read
+from stdin as CSV unless --format says otherwise; Parquet can't be read from
+stdin. Quotes already stored for the same symbol and time are skipped, so
+importing a file twice is harmless.`,
+	Args: cobra.ExactArgs(1),
+	RunE: importRun,
+}
+
+func init() {
+	importCmd.Flags().Int("batch-size", defaultImportBatchSize, "quotes archived per transaction")
+	importCmd.Flags().StringP("format", "f", "", "file format: csv, ndjson or parquet")
+	importCmd.Flags().Bool("progress", true, "report progress on stderr")
+	addFilterFlags(importCmd)
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func importRun(cmd *cobra.Command, args []string) (err error) {
+	path := args[0]
+
+	format, err := fileFormat(cmd, path, codec.CSV)
+	if err != nil {
+		return err
+	}
+
+	filter, err := filterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	if batchSize < 1 {
+		return fmt.Errorf("--batch-size must be positive")
+	}
+
+	in := os.Stdin
+	if path != "-" {
+		in, err = os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = in.Close() }()
+	}
+
+	dec, err := codec.NewDecoder(in, format)
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, dec.Close()) }()
+
+	storage, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, storage.Close()) }()
+
+	showProgress, _ := cmd.Flags().GetBool("progress")
+	p := newProgress(cmd.ErrOrStderr(), "imported", showProgress)
+	skipped := 0
+
+	batch := make([]finance.Quote, 0, batchSize)
+	flush := func() error {
+		quotes, err := newQuotes(cmd.Context(), storage, batch)
+		if err != nil {
+			return err
+		}
+		skipped += len(batch) - len(quotes)
+		batch = batch[:0]
+
+		if len(quotes) == 0 {
+			return nil
+		}
+		if err = storage.SetQuotes(cmd.Context(), quotes); err != nil {
+			return err
+		}
+		p.add(len(quotes))
+
+		return nil
+	}
+
+	for {
+		q, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !filter.Match(q) {
+			continue
+		}
+
+		q.Symbol = strings.ToLower(q.Symbol)
+		batch = append(batch, q)
+		if len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = flush(); err != nil {
+		return err
+	}
+	p.report()
+	if skipped > 0 {
+		_, _ = fmt.Fprintf(p.w, "skipped %d duplicate quotes\n", skipped)
+	}
+
+	return nil
+}
+
+// newQuotes returns the quotes in batch that w doesn't already store, keyed
+// on symbol and time, dropping duplicates within batch as well.
+func newQuotes(ctx context.Context, w history.Walker,
+	batch []finance.Quote) ([]finance.Quote, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	key := func(q finance.Quote) string {
+		return fmt.Sprintf("%s/%d", q.Symbol, q.Time.UnixNano())
+	}
+
+	var (
+		f       history.Filter
+		seen    = make(map[string]struct{}, len(batch))
+		symbols = make(map[string]struct{})
+	)
+	for _, q := range batch {
+		if f.From.IsZero() || q.Time.Before(f.From) {
+			f.From = q.Time
+		}
+		if f.To.IsZero() || !q.Time.Before(f.To) {
+			f.To = q.Time.Add(time.Nanosecond)
+		}
+		if _, ok := symbols[q.Symbol]; !ok {
+			symbols[q.Symbol] = struct{}{}
+			f.Symbols = append(f.Symbols, q.Symbol)
+		}
+	}
+
+	err := w.WalkQuotes(ctx, f, func(q finance.Quote) error {
+		seen[key(q)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding duplicates: %w", err)
+	}
+
+	quotes := make([]finance.Quote, 0, len(batch))
+	for _, q := range batch {
+		k := key(q)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		quotes = append(quotes, q)
+	}
+
+	return quotes, nil
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var pollCmd = &cobra.Command{
+	Use:   "poll",
+	Short: "Poll for quotes and archive them",
+	Long: `Poll IEX Cloud for quotes and archive them in the database without serving
+them; run stonks serve to serve them.`,
+	Args:   cobra.NoArgs,
+	PreRun: daemonPreRun,
+	Run: func(cmd *cobra.Command, _ []string) {
+		daemon(cmd, false, true)
+	},
+}
+
+func init() {
+	addDaemonFlags(pollCmd.Flags())
+	addPollFlags(pollCmd.Flags())
+
+	rootCmd.AddCommand(pollCmd)
+}
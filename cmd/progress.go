@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progress reports a running quote count to w at most once per interval.
+type progress struct {
+	w        io.Writer
+	verb     string
+	interval time.Duration
+	count    int
+	last     time.Time
+}
+
+func (p *progress) add(n int) {
+	p.count += n
+	if time.Since(p.last) >= p.interval {
+		p.report()
+	}
+}
+
+func (p *progress) report() {
+	_, _ = fmt.Fprintf(p.w, "%s %d quotes\n", p.verb, p.count)
+	p.last = time.Now()
+}
+
+func newProgress(w io.Writer, verb string, enabled bool) *progress {
+	if !enabled {
+		w = io.Discard
+	}
+
+	return &progress{w: w, verb: verb, interval: time.Second, last: time.Now()}
+}
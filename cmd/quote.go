@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+)
+
+var quoteCmd = &cobra.Command{
+	Use:   "quote SYMBOL",
+	Short: "Print a symbol's latest quotes from the database",
+	Long: `Print a symbol's latest archived quotes from the database, newest first,
+without calling IEX Cloud.`,
+	Args: cobra.ExactArgs(1),
+	RunE: quoteRun,
+}
+
+func init() {
+	quoteCmd.Flags().IntP("last", "n", 1, "number of quotes to print")
+
+	rootCmd.AddCommand(quoteCmd)
+}
+
+func quoteRun(cmd *cobra.Command, args []string) (err error) {
+	last, _ := cmd.Flags().GetInt("last")
+	if last < 1 {
+		return fmt.Errorf("--last must be positive")
+	}
+
+	storage, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, storage.Close()) }()
+
+	quotes, err := storage.GetQuotes(cmd.Context(), args[0], last)
+	if errors.Is(err, history.ErrNotFound) {
+		return fmt.Errorf("no quotes for %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tPRICE\tTIME")
+	for _, q := range quotes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", q.Symbol,
+			strconv.FormatFloat(q.Price, 'f', -1, 64), q.Time.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,488 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cry0genic/go-stocks/api"
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/finance/iexcloud"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/buffer"
+	"github.com/cry0genic/go-stocks/history/cache"
+	"github.com/cry0genic/go-stocks/history/sqlite"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/poll"
+	"github.com/cry0genic/go-stocks/profiling"
+	"github.com/cry0genic/go-stocks/rpc"
+	"github.com/cry0genic/go-stocks/tlsconfig"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+var (
+	runCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Poll for quotes and serve them",
+		Long: `Poll IEX Cloud for quotes, archive them and serve them over the API and
+gRPC servers, all in one process.`,
+		Args:   cobra.NoArgs,
+		PreRun: daemonPreRun,
+		Run: func(cmd *cobra.Command, _ []string) {
+			daemon(cmd, true, true)
+		},
+	}
+
+	logOutput zapcore.WriteSyncer = os.Stdout
+	logLevel                      = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+)
+
+func init() {
+	addDaemonFlags(runCmd.Flags())
+	addAPIFlags(runCmd.Flags())
+	addCacheFlags(runCmd.Flags())
+	addPollFlags(runCmd.Flags())
+
+	rootCmd.AddCommand(runCmd)
+}
+
+// daemonPreRun validates the settings of run, serve or poll and configures
+// logging.
+func daemonPreRun(cmd *cobra.Command, _ []string) {
+	if err := validateConfig(cmd.Flags()); err != nil {
+		for _, e := range multierr.Errors(err) {
+			log.Print(e)
+		}
+		log.Fatal("invalid configuration")
+	}
+
+	switch strings.ToLower(viper.GetString("log")) {
+	case "stdout", "":
+	default:
+		logOutput = zapcore.AddSync(
+			&lumberjack.Logger{
+				Filename:   viper.GetString("log"),
+				Compress:   viper.GetBool("log-compress"),
+				LocalTime:  viper.GetBool("log-localtime"),
+				MaxAge:     viper.GetInt("log-max-age"),
+				MaxBackups: viper.GetInt("log-max-backups"),
+				MaxSize:    viper.GetInt("log-max-size"),
+			},
+		)
+	}
+
+	level, _ := configuredLogLevel()
+	logLevel.SetLevel(level)
+}
+
+// daemon runs the API and gRPC servers if withAPI, the poller if withPoller,
+// and the metrics and pprof servers, as configured by cmd's flags, until
+// interrupted.
+func daemon(cmd *cobra.Command, withAPI, withPoller bool) {
+	ret := 0
+	defer os.Exit(ret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	zl := zap.New(
+		zapcore.NewCore(
+			zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+			logOutput,
+			logLevel,
+		),
+	).Sugar()
+	defer func() { _ = zl.Sync() }()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		zl.Info("shutting down ...")
+		cancel()
+	}()
+
+	tracer, err := tracing.New(
+		ctx, viper.GetString("tracing"),
+		tracing.Endpoint(viper.GetString("tracing-endpoint")),
+		tracing.SampleRatio(viper.GetFloat64("tracing-sample-ratio")),
+		tracing.ServiceVersion(version),
+	)
+	if err != nil {
+		zl.Error(err)
+		gracefulExit(cancel, &ret)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			zl.Errorf("shutting down tracing: %v", err)
+		}
+	}()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	storage, err := newStorage(sqlite.Registerer(registry))
+	if err != nil {
+		zl.Error(err)
+		gracefulExit(cancel, &ret)
+	}
+
+	var (
+		archiver history.Archiver = storage
+		provider history.Provider = storage
+		buffered *buffer.Archiver
+	)
+	if withAPI && withPoller && viper.GetBool("cache") {
+		c, err := cache.New(
+			storage, storage,
+			cache.Depth(viper.GetInt("cache-depth")),
+			cache.Registerer(registry),
+		)
+		if err != nil {
+			zl.Error(err)
+			_ = storage.Close()
+			gracefulExit(cancel, &ret)
+		}
+		archiver, provider = c, c
+	}
+
+	// The buffer wraps the cache, so the cache sees quotes only once the
+	// database has them and a read that misses the cache can't cache
+	// quotes older than those it already accepted.
+	if withPoller && viper.GetBool("buffer") {
+		buffered, err = buffer.New(
+			archiver, zl,
+			buffer.FlushInterval(viper.GetDuration("buffer-flush-interval")),
+			buffer.FlushSize(viper.GetInt("buffer-flush-size")),
+			buffer.MaxRetries(viper.GetInt("buffer-max-retries")),
+			buffer.MaxSize(viper.GetInt("buffer-max-size")),
+			buffer.Registerer(registry),
+		)
+		if err != nil {
+			zl.Error(err)
+			_ = storage.Close()
+			gracefulExit(cancel, &ret)
+		}
+		archiver = buffered
+	}
+
+	defer func() {
+		if err := archiver.Close(); err != nil {
+			zl.Errorf("closing archiver: %v", err)
+		}
+		zl.Debug("archiver closed")
+	}()
+
+	var (
+		wg        sync.WaitGroup
+		watchlist = finance.NewWatchlist(viper.GetStringSlice("symbols"))
+		poller    *poll.Poller
+	)
+
+	if withPoller {
+		var iexMetrics, iexTracing iexcloud.Option
+		if viper.GetBool("iex-metrics") {
+			iexMetrics = iexcloud.InstrumentHTTPClient(registry)
+		}
+		if tracer.Enabled() {
+			iexTracing = iexcloud.TraceHTTPClient()
+		}
+
+		quotes, err := iexcloud.New(
+			viper.GetString("iex-token"),
+			iexcloud.BatchEndpoint(viper.GetString("iex-batch-endpoint")),
+			iexcloud.CallTimeout(viper.GetDuration("iex-call-timeout")),
+			iexMetrics,
+			iexTracing,
+		)
+		if err != nil {
+			zl.Error(err)
+			gracefulExit(cancel, &ret)
+		}
+
+		var pollOnPriceChange poll.Option
+		if viper.GetBool("poll-price-changes") {
+			pollOnPriceChange = poll.ArchiveOnPriceChange()
+		}
+
+		poller, err = poll.New(quotes, archiver, zl, pollOnPriceChange,
+			poll.Registerer(registry))
+		if err != nil {
+			zl.Error(err)
+			gracefulExit(cancel, &ret)
+		}
+
+		interval := viper.GetDuration("poll")
+		wg.Add(1)
+		go func() {
+			poller.Poll(ctx, interval, watchlist.Symbols()...)
+			wg.Done()
+		}()
+	}
+
+	var certs *tlsconfig.Reloader
+	if withAPI && viper.GetString("api-tls-cert") != "" {
+		certs, err = newCertReloader(zl)
+		if err != nil {
+			zl.Error(err)
+			gracefulExit(cancel, &ret)
+		}
+		go certs.Watch(ctx)
+	}
+
+	// Catch SIGHUP now, rather than be killed by one, and handle it once
+	// started.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// The REST and gRPC servers share each API key's rate limit.
+	limiter := auth.NewRateLimiter(viper.GetFloat64("api-rate-limit"),
+		viper.GetInt("api-rate-burst"))
+
+	if withAPI {
+		var apiMetrics api.Option
+		if !viper.GetBool("api-metrics") {
+			apiMetrics = api.DisableInstrumentation()
+		}
+		var apiAuth api.Option
+		if viper.GetBool("api-auth") {
+			apiAuth = api.Authenticate(storage)
+		}
+		var apiMetricsHandler api.Option
+		if viper.GetBool("metrics-on-api") {
+			apiMetricsHandler = api.Metrics(viper.GetString("metrics-path"),
+				metrics.Handler(registry, viper.GetString("metrics-username"),
+					viper.GetString("metrics-password")))
+		}
+
+		info := api.Info{
+			Version: version,
+			Commit:  commit,
+			Storage: "sqlite",
+		}
+		var apiTLS, apiClientCert api.Option
+		if certs != nil {
+			apiTLS = api.TLSConfig(certs.Config())
+			if certs.RequiresClientCert() {
+				apiClientCert = api.RequireClientCert()
+			}
+		}
+		var bufferReady api.Option
+		if buffered != nil {
+			bufferReady = api.ReadinessCheck("buffer", buffered.Ready)
+		}
+		var pollerReady api.Option
+		if poller != nil {
+			info.PollInterval = func() time.Duration {
+				return poller.Status().Interval
+			}
+			pollerReady = api.ReadinessCheck("poller", func(context.Context) error {
+				return poller.Ready()
+			})
+		}
+
+		server, err := api.New(
+			ctx, provider, zl,
+			apiAuth,
+			apiMetrics,
+			apiMetricsHandler,
+			api.Registerer(registry),
+			api.AccessLogSampling(viper.GetInt("api-access-log-sample-first"), viper.GetInt("api-access-log-sample-thereafter")),
+			api.BuildInfo(info),
+			api.GraphQLMaxComplexity(viper.GetInt("api-graphql-max-complexity")),
+			api.GraphQLMaxDepth(viper.GetInt("api-graphql-max-depth")),
+			api.IdleTimeout(viper.GetDuration("api-idle-timeout")),
+			api.ListenAddress(viper.GetString("api-listen-addr")),
+			api.MaxLast(viper.GetInt("api-max-last")),
+			api.RateLimiter(limiter),
+			api.ReadHeaderTimeout(viper.GetDuration("api-read-headers-timeout")),
+			api.ReadinessCheck("archiver", storage.Ping),
+			bufferReady,
+			apiTLS,
+			apiClientCert,
+			pollerReady,
+			api.Watchlist(watchlist),
+		)
+		if err != nil {
+			zl.Error(err)
+			gracefulExit(cancel, &ret)
+		}
+
+		wg.Add(1)
+		go func() {
+			var sErr error
+			if certs != nil {
+				// The TLS configuration provides the certificate.
+				sErr = server.ListenAndServeTLS("", "")
+			} else {
+				sErr = server.ListenAndServe()
+			}
+			if sErr != nil && sErr != http.ErrServerClosed {
+				zl.Errorf("API server: %v", sErr)
+			}
+			wg.Done()
+		}()
+
+	}
+
+	if withAPI && viper.GetString("grpc-listen-addr") != "" {
+		var grpcMetrics rpc.Option
+		if !viper.GetBool("grpc-metrics") {
+			grpcMetrics = rpc.DisableInstrumentation()
+		}
+		var grpcAuth rpc.Option
+		if viper.GetBool("api-auth") {
+			grpcAuth = rpc.Authenticate(storage)
+		}
+		var grpcTLS, grpcClientCert rpc.Option
+		if certs != nil {
+			grpcTLS = rpc.TLSConfig(certs.Config())
+			if certs.RequiresClientCert() {
+				grpcClientCert = rpc.RequireClientCert()
+			}
+		}
+		grpcServer, err := rpc.New(
+			ctx, provider, zl,
+			grpcAuth,
+			grpcClientCert,
+			grpcMetrics,
+			grpcTLS,
+			rpc.Registerer(registry),
+			rpc.ListenAddress(viper.GetString("grpc-listen-addr")),
+			rpc.MaxLast(viper.GetInt("api-max-last")),
+			rpc.RateLimiter(limiter),
+			rpc.StreamInterval(viper.GetDuration("grpc-stream-interval")),
+			rpc.Watchlist(watchlist),
+		)
+		if err != nil {
+			zl.Error(err)
+			gracefulExit(cancel, &ret)
+		}
+
+		wg.Add(1)
+		go func() {
+			if sErr := grpcServer.ListenAndServe(); sErr != nil {
+				zl.Errorf("gRPC server: %v", sErr)
+			}
+			wg.Done()
+		}()
+	}
+
+	if !viper.GetBool("metrics-on-api") &&
+		viper.GetString("metrics-listen-addr") != "" {
+		if viper.GetString("metrics-username") == "" &&
+			!isLoopback(viper.GetString("metrics-listen-addr")) {
+			zl.Warnf("serving metrics on %q without basic auth",
+				viper.GetString("metrics-listen-addr"))
+		}
+		metricsServer := metrics.New(
+			ctx, registry, zl,
+			metrics.BasicAuth(viper.GetString("metrics-username"), viper.GetString("metrics-password")),
+			metrics.ListenAddress(viper.GetString("metrics-listen-addr")),
+			metrics.Path(viper.GetString("metrics-path")),
+		)
+
+		cert, key := viper.GetString("metrics-tls-cert"), viper.GetString("metrics-tls-key")
+		wg.Add(1)
+		go func() {
+			var sErr error
+			if cert != "" {
+				sErr = metricsServer.ListenAndServeTLS(cert, key)
+			} else {
+				sErr = metricsServer.ListenAndServe()
+			}
+			if sErr != nil && sErr != http.ErrServerClosed {
+				zl.Errorf("metrics server: %v", sErr)
+			}
+			wg.Done()
+		}()
+	}
+
+	if viper.GetBool("pprof") {
+		if viper.GetString("pprof-username") == "" &&
+			!isLoopback(viper.GetString("pprof-addr")) {
+			zl.Warnf("serving pprof on %q without basic auth",
+				viper.GetString("pprof-addr"))
+		}
+		pprofServer := profiling.New(
+			ctx, zl,
+			profiling.BasicAuth(viper.GetString("pprof-username"), viper.GetString("pprof-password")),
+			profiling.ListenAddress(viper.GetString("pprof-addr")),
+		)
+
+		wg.Add(1)
+		go func() {
+			sErr := pprofServer.ListenAndServe()
+			if sErr != nil && sErr != http.ErrServerClosed {
+				zl.Errorf("pprof server: %v", sErr)
+			}
+			wg.Done()
+		}()
+	}
+
+	// The reloader is the only user of viper from here on.
+	go newReloader(cmd.Flags(), zl, poller, watchlist, certs).watch(ctx, hup)
+
+	wg.Wait()
+}
+
+// newCertReloader returns a reloader serving the API server's certificate
+// with the api-tls-* settings.
+func newCertReloader(l *zap.SugaredLogger) (*tlsconfig.Reloader, error) {
+	version, err := tlsconfig.ParseVersion(viper.GetString("api-tls-min-version"))
+	if err != nil {
+		return nil, err
+	}
+	suites, err := tlsconfig.ParseCipherSuites(
+		viper.GetStringSlice("api-tls-cipher-suites"))
+	if err != nil {
+		return nil, err
+	}
+
+	return tlsconfig.New(
+		viper.GetString("api-tls-cert"), viper.GetString("api-tls-key"), l,
+		tlsconfig.CipherSuites(suites),
+		tlsconfig.ClientCA(viper.GetString("api-tls-client-ca")),
+		tlsconfig.MinVersion(version),
+	)
+}
+
+// isLoopback returns true if addr's host is localhost or a loopback IP.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+func gracefulExit(cancel context.CancelFunc, ret *int) {
+	cancel()
+	*ret = 1
+	runtime.Goexit()
+}
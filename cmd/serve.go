@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve archived quotes",
+	Long: `Serve the quotes in the database over the API and gRPC servers without
+polling for them, such as from a read-only replica of a database another
+process runs stonks poll against.`,
+	Args:   cobra.NoArgs,
+	PreRun: daemonPreRun,
+	Run: func(cmd *cobra.Command, _ []string) {
+		daemon(cmd, true, false)
+	},
+}
+
+func init() {
+	addDaemonFlags(serveCmd.Flags())
+	addAPIFlags(serveCmd.Flags())
+
+	rootCmd.AddCommand(serveCmd)
+}
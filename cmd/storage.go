@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/sqlite"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// newStorage opens the SQLite database configured by the sqlite-* flags.
+func newStorage(options ...sqlite.Option) (*sqlite.Client, error) {
+	return sqlite.New(append([]sqlite.Option{
+		sqlite.BusyTimeout(viper.GetDuration("sqlite-busy-timeout")),
+		sqlite.ConnMaxLifetime(viper.GetDuration("sqlite-conn-max-lifetime")),
+		sqlite.DatabaseFile(viper.GetString("sqlite-database")),
+		sqlite.JournalMode(viper.GetString("sqlite-journal-mode")),
+		sqlite.MaxIdleConnections(viper.GetInt("sqlite-max-idle-conn")),
+		sqlite.Symbols(viper.GetStringSlice("symbols")),
+	}, options...)...)
+}
+
+// addFilterFlags adds the flags parsed by filterFromFlags to cmd.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "", "earliest quote time (RFC 3339 or YYYY-MM-DD)")
+	cmd.Flags().StringSliceP("symbols", "s", nil, "stock symbols (default all)")
+	cmd.Flags().String("to", "", "quote time to stop before (RFC 3339 or YYYY-MM-DD)")
+}
+
+func filterFromFlags(cmd *cobra.Command) (history.Filter, error) {
+	var (
+		f   history.Filter
+		err error
+	)
+
+	f.Symbols, err = cmd.Flags().GetStringSlice("symbols")
+	if err != nil {
+		return f, err
+	}
+	for i := range f.Symbols {
+		f.Symbols[i] = strings.ToLower(f.Symbols[i])
+	}
+
+	for name, t := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		s, err := cmd.Flags().GetString(name)
+		if err != nil {
+			return f, err
+		}
+		if *t, err = parseTime(s); err != nil {
+			return f, fmt.Errorf("--%s: %w", name, err)
+		}
+	}
+
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, fmt.Errorf("--from must be before --to")
+	}
+
+	return f, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/watch"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch quotes update in the terminal",
+	Long: `Watch a live table of each symbol's last price, change since the day's
+first quote, recent trend and time since its last update, read from the
+database or, with --url, from a stonks API server.
+
+The table redraws on each refresh. When stdout isn't a terminal, or with
+--plain, each new quote is written on a line of its own instead.`,
+	Args: cobra.NoArgs,
+	RunE: watchRun,
+}
+
+func init() {
+	watchCmd.Flags().String("api-key", "", "API key for --url")
+	watchCmd.Flags().String("filter", "", `show only symbols matching a pattern, such as "a*"`)
+	watchCmd.Flags().Int("history", watch.DefaultHistory, "quotes per symbol read at start to find the day's first quote")
+	watchCmd.Flags().Duration("interval", watch.DefaultInterval, "duration between refreshes")
+	watchCmd.Flags().Bool("plain", false, "write a line per new quote even on a terminal")
+	watchCmd.Flags().String("sort", watch.DefaultSort, `sort by symbol, price, change or updated; prefix with "-" to reverse`)
+	watchCmd.Flags().StringSliceP("symbols", "s", nil, "stock symbols (default the server's with --url, otherwise "+strings.Join(finance.DefaultSymbols, ",")+")")
+	watchCmd.Flags().Int("ticks", watch.DefaultTicks, "recent quotes plotted per symbol")
+	watchCmd.Flags().String("url", "", "stonks API server URL, such as http://localhost:18081 (default read the database)")
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+func watchRun(cmd *cobra.Command, _ []string) (err error) {
+	symbols := viper.GetStringSlice("symbols")
+
+	var src watch.Source
+	if u := viper.GetString("url"); u != "" {
+		src, err = watch.NewRemote(u, viper.GetString("api-key"))
+		if err != nil {
+			return err
+		}
+	} else {
+		storage, sErr := newStorage()
+		if sErr != nil {
+			return sErr
+		}
+		defer func() { err = multierr.Append(err, storage.Close()) }()
+		src = storage
+
+		if len(symbols) == 0 {
+			symbols = finance.DefaultSymbols
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	w, err := watch.New(src, out,
+		watch.ErrorOutput(cmd.ErrOrStderr()),
+		watch.Filter(viper.GetString("filter")),
+		watch.History(viper.GetInt("history")),
+		watch.Interval(viper.GetDuration("interval")),
+		watch.Sort(viper.GetString("sort")),
+		watch.Symbols(symbols),
+		watch.Terminal(!viper.GetBool("plain") && isTerminal(out)),
+		watch.Ticks(viper.GetInt("ticks")),
+	)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt,
+		syscall.SIGTERM)
+	defer stop()
+
+	return w.Run(ctx)
+}
+
+// isTerminal returns true if w is a terminal.
+func isTerminal(w interface{}) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -12,6 +12,11 @@ import (
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -32,14 +37,21 @@ type Client struct {
 	token         string
 
 	httpClient *http.Client
+
+	instrumented bool
+	registerer   prometheus.Registerer
 }
 
 func (c Client) GetQuotes(ctx context.Context, symbols ...string) (
-	[]finance.Quote, error) {
+	_ []finance.Quote, err error) {
 	if len(symbols) == 0 {
 		return nil, fmt.Errorf("empty symbols")
 	}
 
+	ctx, span := tracer.Start(ctx, "iexcloud.GetQuotes", trace.WithAttributes(
+		attribute.Int("iexcloud.symbols", len(symbols))))
+	defer func() { tracing.End(span, err) }()
+
 	v := url.Values{}
 	v.Add("types", "quote")
 	v.Add("token", c.token)
@@ -60,6 +72,10 @@ func (c Client) GetQuotes(ctx context.Context, symbols ...string) (
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		// The request URL holds the token, so keep it out of logs and spans.
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = c.batchEndpoint
+		}
 		return nil, err
 	}
 	defer func() {
@@ -108,5 +124,13 @@ func New(token string, options ...Option) (*Client, error) {
 			err)
 	}
 
+	if c.instrumented {
+		m, err := metrics.NewHTTPClient(c.registerer)
+		if err != nil {
+			return nil, err
+		}
+		wrapTransport(c, instrumentTransport(m))
+	}
+
 	return c, nil
 }
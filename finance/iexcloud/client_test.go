@@ -2,13 +2,18 @@ package iexcloud
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewClientDefaults(t *testing.T) {
@@ -48,7 +53,7 @@ func TestNewClientOptions(t *testing.T) {
 		"to the moon!",
 		BatchEndpoint(endpoint),
 		CallTimeout(timeout),
-		InstrumentHTTPClient(),
+		InstrumentHTTPClient(prometheus.NewRegistry()),
 	)
 	if err != nil {
 		t.Error(err)
@@ -113,3 +118,61 @@ func TestClientGetQuotes(t *testing.T) {
 		}
 	}
 }
+
+func TestClientGetQuotesRedactsToken(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	endpoint := srv.URL + "/"
+	srv.Close()
+
+	c, err := New("secret", BatchEndpoint(endpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.GetQuotes(context.Background(), "fb")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("token in error: %v", err)
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		},
+	))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	c, err := New("secret", BatchEndpoint(srv.URL),
+		InstrumentHTTPClient(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.GetQuotes(context.Background(), "fb"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP client_api_requests_total A counter for requests from the wrapped client.
+# TYPE client_api_requests_total counter
+client_api_requests_total{code="200",method="get"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"client_api_requests_total")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// A second client shares the registered metrics.
+	if _, err = New("secret", InstrumentHTTPClient(reg)); err != nil {
+		t.Error(err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -26,34 +27,63 @@ func CallTimeout(timeout time.Duration) Option {
 	}
 }
 
-func InstrumentHTTPClient() Option {
+// InstrumentHTTPClient collects metrics for requests to IEX Cloud and
+// registers them with r.
+func InstrumentHTTPClient(r prometheus.Registerer) Option {
 	return func(c *Client) {
-		
-		trace := &promhttp.InstrumentTrace{
-			DNSStart: func(t float64) {
-				metrics.ClientDNSDuration.WithLabelValues("dns_start").Observe(t)
-			},
-			DNSDone: func(t float64) {
-				metrics.ClientDNSDuration.WithLabelValues("dns_done").Observe(t)
-			},
-			TLSHandshakeStart: func(t float64) {
-				metrics.ClientTLSDuration.WithLabelValues("tls_handshake_start").Observe(t)
-			},
-			TLSHandshakeDone: func(t float64) {
-				metrics.ClientTLSDuration.WithLabelValues("tls_handshake_done").Observe(t)
-			},
-		}
+		c.instrumented = true
+		c.registerer = r
+	}
+}
 
-		roundTripper := promhttp.InstrumentRoundTripperInFlight(metrics.ClientInFlightRequests,
-			promhttp.InstrumentRoundTripperCounter(metrics.ClientAPIRequests,
+func instrumentTransport(m *metrics.HTTPClient) func(
+	http.RoundTripper) http.RoundTripper {
+	trace := &promhttp.InstrumentTrace{
+		DNSStart: func(t float64) {
+			m.DNSDuration.WithLabelValues("dns_start").Observe(t)
+		},
+		DNSDone: func(t float64) {
+			m.DNSDuration.WithLabelValues("dns_done").Observe(t)
+		},
+		TLSHandshakeStart: func(t float64) {
+			m.TLSDuration.WithLabelValues("tls_handshake_start").Observe(t)
+		},
+		TLSHandshakeDone: func(t float64) {
+			m.TLSDuration.WithLabelValues("tls_handshake_done").Observe(t)
+		},
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return promhttp.InstrumentRoundTripperInFlight(m.InFlightRequests,
+			promhttp.InstrumentRoundTripperCounter(m.Requests,
 				promhttp.InstrumentRoundTripperTrace(trace,
-					promhttp.InstrumentRoundTripperDuration(metrics.ClientRequestDuration,
-						http.DefaultTransport,
+					promhttp.InstrumentRoundTripperDuration(m.RequestDuration,
+						next,
 					),
 				),
 			),
 		)
+	}
+}
+
+// TraceHTTPClient starts a span for each request to IEX Cloud.
+func TraceHTTPClient() Option {
+	return func(c *Client) {
+		wrapTransport(c, func(next http.RoundTripper) http.RoundTripper {
+			return tracingTransport{next: next}
+		})
+	}
+}
 
-		c.httpClient.Transport = roundTripper
+// wrapTransport wraps the client's transport in a copy of its HTTP client,
+// leaving the default, http.DefaultClient, untouched.
+func wrapTransport(c *Client, wrap func(http.RoundTripper) http.RoundTripper) {
+	next := c.httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
 	}
+
+	hc := *c.httpClient
+	hc.Transport = wrap(next)
+	c.httpClient = &hc
 }
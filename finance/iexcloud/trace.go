@@ -0,0 +1,44 @@
+package iexcloud
+
+import (
+	"net/http"
+
+	"github.com/cry0genic/go-stocks/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/cry0genic/go-stocks/finance/iexcloud")
+
+// tracingTransport records a client span per request. Unlike otelhttp's
+// transport, it leaves out the query string, which holds the API token, and
+// doesn't propagate the trace to IEX Cloud.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(r *http.Request) (resp *http.Response,
+	err error) {
+	ctx, span := tracer.Start(r.Context(), "HTTP "+r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPHostKey.String(r.URL.Host),
+			attribute.String("http.path", r.URL.Path),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	resp, err = t.next.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,54 @@
+package finance
+
+import (
+	"strings"
+	"sync"
+)
+
+// Watchlist holds the tracked symbols, which may change while the service
+// runs. It's safe for concurrent use.
+type Watchlist struct {
+	mu      sync.RWMutex
+	symbols []string
+}
+
+// Len returns the number of tracked symbols.
+func (w *Watchlist) Len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return len(w.symbols)
+}
+
+// Set replaces the tracked symbols with the non-empty symbols given,
+// lowercased.
+func (w *Watchlist) Set(symbols []string) {
+	lc := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		if symbol != "" {
+			lc = append(lc, strings.ToLower(symbol))
+		}
+	}
+
+	w.mu.Lock()
+	w.symbols = lc
+	w.mu.Unlock()
+}
+
+// Symbols returns a copy of the tracked symbols.
+func (w *Watchlist) Symbols() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	symbols := make([]string, len(w.symbols))
+	copy(symbols, w.symbols)
+
+	return symbols
+}
+
+func NewWatchlist(symbols []string) *Watchlist {
+	w := new(Watchlist)
+	w.Set(symbols)
+
+	return w
+}
@@ -0,0 +1,266 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultFlushInterval = 5 * time.Second
+	DefaultFlushSize     = 100
+	DefaultMaxRetries    = 0 // never drop quotes
+	DefaultMaxSize       = 1000
+)
+
+var (
+	tracer = otel.Tracer("github.com/cry0genic/go-stocks/history/buffer")
+
+	_ history.Archiver = (*Archiver)(nil)
+
+	ErrClosed      = fmt.Errorf("archiver closed")
+	ErrNilArchiver = fmt.Errorf("archiver cannot be nil")
+	ErrNilLogger   = fmt.Errorf("logger cannot be nil")
+)
+
+// Archiver is a write-behind history.Archiver. It accumulates quotes in
+// memory and hands them to the wrapped archiver in a single SetQuotes call
+// once the buffer holds flushSize quotes or every flushInterval, whichever
+// comes first. SetQuotes blocks while the buffer is at maxSize. If maxRetries
+// is positive, after that many failed flushes in a row it drops the quotes it
+// failed to flush so a batch the wrapped archiver never accepts can't block
+// SetQuotes.
+type Archiver struct {
+	archiver      history.Archiver
+	log           *zap.SugaredLogger
+	flushInterval time.Duration
+	flushSize     int
+	maxRetries    int
+	maxSize       int
+	metrics       *metrics.Archiver
+	registerer    prometheus.Registerer
+
+	mu       sync.Mutex
+	buf      []finance.Quote
+	inFlight int
+	failures int   // flushes failed in a row
+	err      error // the last flush's error, if it failed
+	closed   bool
+	space    chan struct{} // closed when a flush frees up room in buf
+	flush    chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Close flushes any buffered quotes and closes the wrapped archiver.
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	close(a.done)
+	a.wg.Wait()
+
+	return multierr.Append(
+		a.flushBuffer(context.Background()),
+		a.archiver.Close(),
+	)
+}
+
+// Ready returns the last flush's error while quotes wait for a retry, so
+// readiness checks report a wrapped archiver that stopped accepting quotes
+// until the backlog drains.
+func (a *Archiver) Ready(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.buf)+a.inFlight == 0 {
+		return nil
+	}
+
+	return a.err
+}
+
+func (a *Archiver) SetQuotes(ctx context.Context, quotes []finance.Quote) error {
+	if len(quotes) == 0 {
+		return nil
+	}
+
+	for {
+		a.mu.Lock()
+		if a.closed {
+			a.mu.Unlock()
+			return ErrClosed
+		}
+
+		// An empty buffer accepts a batch larger than maxSize so oversized
+		// batches cannot block forever.
+		queued := len(a.buf) + a.inFlight
+		if queued == 0 || queued+len(quotes) <= a.maxSize {
+			a.buf = append(a.buf, quotes...)
+			depth := len(a.buf)
+			a.mu.Unlock()
+
+			a.metrics.QueueDepth.Set(float64(queued + len(quotes)))
+			if depth >= a.flushSize {
+				select {
+				case a.flush <- struct{}{}:
+				default:
+				}
+			}
+
+			return nil
+		}
+
+		space := a.space
+		a.mu.Unlock()
+
+		select {
+		case a.flush <- struct{}{}:
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-space:
+		}
+	}
+}
+
+func (a *Archiver) run() {
+	defer a.wg.Done()
+
+	t := time.NewTicker(a.flushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-t.C:
+		case <-a.flush:
+		}
+
+		if err := a.flushBuffer(context.Background()); err != nil {
+			a.log.Errorf("flushing buffer: %v", err)
+		}
+	}
+}
+
+// flushBuffer hands the buffered quotes to the wrapped archiver. Quotes the
+// wrapped archiver fails to store go back to the front of the buffer so the
+// next flush retries them, unless maxRetries is positive and the flush was the
+// last of maxRetries failures in a row.
+func (a *Archiver) flushBuffer(ctx context.Context) (err error) {
+	a.mu.Lock()
+	batch := a.buf
+	a.buf = nil
+	a.inFlight = len(batch)
+	a.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "buffer.flush", trace.WithAttributes(
+		attribute.Int("stonks.quotes", len(batch))))
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	err = a.archiver.SetQuotes(ctx, batch)
+	a.metrics.FlushDuration.Observe(time.Since(start).Seconds())
+
+	dropped := false
+	a.mu.Lock()
+	a.err = err
+	switch {
+	case err == nil:
+		a.failures = 0
+	case a.maxRetries > 0 && a.failures+1 >= a.maxRetries:
+		a.failures = 0
+		dropped = true
+	default:
+		a.failures++
+		a.buf = append(batch, a.buf...)
+	}
+	a.inFlight = 0
+	depth := len(a.buf)
+	close(a.space)
+	a.space = make(chan struct{})
+	a.mu.Unlock()
+
+	a.metrics.QueueDepth.Set(float64(depth))
+	if dropped {
+		a.metrics.DroppedQuotes.Add(float64(len(batch)))
+		return fmt.Errorf("dropped %d quotes after %d failed flushes: %w",
+			len(batch), a.maxRetries, err)
+	}
+	if err != nil {
+		return fmt.Errorf("flushing %d quotes: %w", len(batch), err)
+	}
+	a.metrics.FlushedQuotes.Add(float64(len(batch)))
+	a.log.Debugf("flushed %d quotes", len(batch))
+
+	return nil
+}
+
+func New(a history.Archiver, l *zap.SugaredLogger, options ...Option) (
+	*Archiver, error) {
+	switch {
+	case a == nil:
+		return nil, ErrNilArchiver
+	case l == nil:
+		return nil, ErrNilLogger
+	}
+
+	b := &Archiver{
+		archiver:      a,
+		log:           l.Named("buffer"),
+		flushInterval: DefaultFlushInterval,
+		flushSize:     DefaultFlushSize,
+		maxRetries:    DefaultMaxRetries,
+		maxSize:       DefaultMaxSize,
+		space:         make(chan struct{}),
+		flush:         make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(b)
+		}
+	}
+
+	var err error
+	b.metrics, err = metrics.NewArchiver(b.registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.maxSize < b.flushSize {
+		b.maxSize = b.flushSize
+	}
+	b.buf = make([]finance.Quote, 0, b.maxSize)
+
+	b.wg.Add(1)
+	go b.run()
+
+	return b, nil
+}
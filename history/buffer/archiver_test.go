@@ -0,0 +1,359 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/cache"
+	"github.com/cry0genic/go-stocks/history/memory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewArchiver(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(nil, nil)
+	if err != ErrNilArchiver {
+		t.Errorf("expected ErrNilArchiver: %v", err)
+	}
+
+	_, err = New(new(mockArchiver), nil)
+	if err != ErrNilLogger {
+		t.Errorf("expected ErrNilLogger: %v", err)
+	}
+
+	a, err := New(new(mockArchiver), zaptest.NewLogger(t).Sugar(),
+		FlushSize(10), MaxSize(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Close() }()
+
+	if a.maxSize != a.flushSize {
+		t.Errorf("expected max size %d; actual: %d", a.flushSize, a.maxSize)
+	}
+}
+
+func TestArchiverFlushSize(t *testing.T) {
+	t.Parallel()
+
+	m := new(mockArchiver)
+	a, err := New(m, zaptest.NewLogger(t).Sugar(),
+		FlushInterval(time.Hour), FlushSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Close() }()
+
+	quotes := []finance.Quote{
+		{Price: 123.45, Symbol: "fb"},
+		{Price: 234.56, Symbol: "goog"},
+	}
+	for _, q := range quotes {
+		if err = a.SetQuotes(context.Background(), []finance.Quote{q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actual := m.wait(t, 1)
+	if !reflect.DeepEqual(actual, [][]finance.Quote{quotes}) {
+		t.Error("flushed batches do not equal expected")
+		t.Logf("expected: %#v", [][]finance.Quote{quotes})
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestArchiverFlushInterval(t *testing.T) {
+	t.Parallel()
+
+	m := new(mockArchiver)
+	a, err := New(m, zaptest.NewLogger(t).Sugar(),
+		FlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Close() }()
+
+	quotes := []finance.Quote{{Price: 123.45, Symbol: "fb"}}
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := m.wait(t, 1)
+	if !reflect.DeepEqual(actual, [][]finance.Quote{quotes}) {
+		t.Error("flushed batches do not equal expected")
+		t.Logf("expected: %#v", [][]finance.Quote{quotes})
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestArchiverClose(t *testing.T) {
+	t.Parallel()
+
+	m := new(mockArchiver)
+	a, err := New(m, zaptest.NewLogger(t).Sugar(),
+		FlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes := []finance.Quote{{Price: 123.45, Symbol: "fb"}}
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !m.closed {
+		t.Error("wrapped archiver not closed")
+	}
+	if !reflect.DeepEqual(m.batches, [][]finance.Quote{quotes}) {
+		t.Error("buffered quotes not flushed on close")
+	}
+
+	if err = a.SetQuotes(context.Background(), quotes); err != ErrClosed {
+		t.Errorf("expected ErrClosed: %v", err)
+	}
+}
+
+func TestArchiverBackpressure(t *testing.T) {
+	t.Parallel()
+
+	m := &mockArchiver{block: make(chan struct{})}
+	a, err := New(m, zaptest.NewLogger(t).Sugar(),
+		FlushInterval(time.Hour), FlushSize(1), MaxSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first quote triggers a flush that blocks in the wrapped archiver.
+	quotes := []finance.Quote{{Price: 123.45, Symbol: "fb"}}
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	err = a.SetQuotes(ctx, quotes)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded: %v", err)
+	}
+
+	close(m.block)
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Error(err)
+	}
+	if err = a.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(m.batches) != 2 {
+		t.Errorf("expected 2 flushed batches; actual: %d", len(m.batches))
+	}
+}
+
+func TestArchiverFlushError(t *testing.T) {
+	t.Parallel()
+
+	m := &mockArchiver{err: fmt.Errorf("database is locked")}
+	a, err := New(m, zaptest.NewLogger(t).Sugar(),
+		FlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes := []finance.Quote{{Price: 123.45, Symbol: "fb"}}
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	// By default, the buffer never drops the quotes it fails to flush.
+	for i := 0; i < 10; i++ {
+		if err = a.flushBuffer(context.Background()); err == nil {
+			t.Fatalf("%d: expected a flush error", i)
+		}
+	}
+	if !reflect.DeepEqual(a.buf, quotes) {
+		t.Error("failed quotes not returned to the buffer")
+	}
+	if err = a.Ready(context.Background()); err == nil {
+		t.Error("expected the failed flush to fail the readiness check")
+	}
+
+	m.mu.Lock()
+	m.err = nil
+	m.mu.Unlock()
+
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.batches, [][]finance.Quote{quotes}) {
+		t.Error("failed quotes not retried")
+	}
+}
+
+func TestArchiverFlushRetries(t *testing.T) {
+	t.Parallel()
+
+	m := &mockArchiver{err: fmt.Errorf("constraint failed")}
+	a, err := New(m, zaptest.NewLogger(t).Sugar(),
+		FlushInterval(time.Hour), MaxRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Close() }()
+
+	quotes := []finance.Quote{{Price: 123.45, Symbol: "fb"}}
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.flushBuffer(context.Background()); err == nil {
+		t.Error("expected a flush error")
+	}
+	if err = a.Ready(context.Background()); err == nil {
+		t.Error("expected the failed flush to fail the readiness check")
+	}
+	if err = a.flushBuffer(context.Background()); err == nil {
+		t.Error("expected a flush error")
+	}
+	if len(a.buf) != 0 {
+		t.Errorf("expected the quotes dropped; actual buffer: %v", a.buf)
+	}
+	if actual := testutil.ToFloat64(a.metrics.DroppedQuotes); actual != 1 {
+		t.Errorf("actual dropped quotes: %v; expected: 1", actual)
+	}
+	// With the backlog gone, the buffer is ready again.
+	if err = a.Ready(context.Background()); err != nil {
+		t.Errorf("unexpected readiness error: %v", err)
+	}
+
+	// The buffer accepts and flushes quotes again once the archiver does.
+	m.mu.Lock()
+	m.err = nil
+	m.mu.Unlock()
+	if err = a.SetQuotes(context.Background(), quotes); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.flushBuffer(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Ready(context.Background()); err != nil {
+		t.Errorf("unexpected readiness error: %v", err)
+	}
+	if !reflect.DeepEqual(m.batches, [][]finance.Quote{quotes}) {
+		t.Errorf("actual batches: %v", m.batches)
+	}
+}
+
+// The buffer wraps the cache, so a read that misses the cache while quotes
+// wait in the buffer doesn't keep serving stale quotes after the flush.
+func TestArchiverCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Date(2021, 5, 7, 19, 31, 5, 0, time.UTC)
+	m := memory.New()
+	old := finance.Quote{Price: 123.45, Symbol: "fb", Time: now}
+	if err := m.SetQuotes(ctx, []finance.Quote{old}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cache.New(m, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(c, zaptest.NewLogger(t).Sugar(), FlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Close() }()
+
+	fresh := finance.Quote{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)}
+	if err = a.SetQuotes(ctx, []finance.Quote{fresh}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cold miss caches the stored quotes while the fresh one waits.
+	quotes, err := c.GetQuotes(ctx, "fb", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quotes, []finance.Quote{old}) {
+		t.Errorf("before flush: actual: %v; expected: %v", quotes, old)
+	}
+
+	if err = a.flushBuffer(ctx); err != nil {
+		t.Fatal(err)
+	}
+	quotes, err = c.GetQuotes(ctx, "fb", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []finance.Quote{fresh, old}
+	if !reflect.DeepEqual(quotes, expected) {
+		t.Errorf("after flush: actual: %v; expected: %v", quotes, expected)
+	}
+}
+
+var _ history.Archiver = (*mockArchiver)(nil)
+
+type mockArchiver struct {
+	mu      sync.Mutex
+	batches [][]finance.Quote
+	block   chan struct{}
+	closed  bool
+	err     error
+}
+
+func (m *mockArchiver) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+
+	return nil
+}
+
+func (m *mockArchiver) SetQuotes(_ context.Context,
+	quotes []finance.Quote) error {
+	if m.block != nil {
+		<-m.block
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.batches = append(m.batches, quotes)
+
+	return nil
+}
+
+// wait for the archiver to receive at least n batches.
+func (m *mockArchiver) wait(t *testing.T, n int) [][]finance.Quote {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.Lock()
+		if len(m.batches) >= n {
+			batches := m.batches
+			m.mu.Unlock()
+			return batches
+		}
+		m.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d batches", n)
+
+	return nil
+}
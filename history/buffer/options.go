@@ -0,0 +1,50 @@
+package buffer
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Option func(*Archiver)
+
+func FlushInterval(d time.Duration) Option {
+	return func(a *Archiver) {
+		if d > 0 {
+			a.flushInterval = d
+		}
+	}
+}
+
+func FlushSize(i int) Option {
+	return func(a *Archiver) {
+		if i > 0 {
+			a.flushSize = i
+		}
+	}
+}
+
+// MaxRetries sets how many flushes in a row may fail before the archiver
+// drops the quotes it failed to flush. By default, it never drops them.
+func MaxRetries(i int) Option {
+	return func(a *Archiver) {
+		if i > 0 {
+			a.maxRetries = i
+		}
+	}
+}
+
+func MaxSize(i int) Option {
+	return func(a *Archiver) {
+		if i > 0 {
+			a.maxSize = i
+		}
+	}
+}
+
+// Registerer registers the archiver's metrics with r.
+func Registerer(r prometheus.Registerer) Option {
+	return func(a *Archiver) {
+		a.registerer = r
+	}
+}
@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultDepth = 100
+
+var (
+	tracer = otel.Tracer("github.com/cry0genic/go-stocks/history/cache")
+
+	_ history.Archiver = (*Client)(nil)
+	_ history.Pager    = (*Client)(nil)
+	_ history.Provider = (*Client)(nil)
+	_ history.Walker   = (*Client)(nil)
+
+	ErrNilArchiver = fmt.Errorf("archiver cannot be nil")
+	ErrNilProvider = fmt.Errorf("provider cannot be nil")
+)
+
+// entry holds the newest quotes for a symbol, newest first.
+type entry struct {
+	quotes []finance.Quote
+
+	// complete is true when quotes holds the symbol's entire history, in
+	// which case the backend has nothing more to offer.
+	complete bool
+}
+
+// Client is a read-through history.Provider that keeps the latest depth
+// quotes per symbol in memory. It doubles as a history.Archiver so quotes
+// accepted by the wrapped archiver update the cache as they're written.
+// Requests for more than depth quotes go straight to the wrapped provider.
+type Client struct {
+	provider history.Provider
+	archiver history.Archiver
+	depth    int
+
+	metrics    *metrics.Cache
+	registerer prometheus.Registerer
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	// generations counts the writes per symbol so a backend read that races
+	// a write doesn't cache quotes older than those just written.
+	generations map[string]uint64
+}
+
+func (c *Client) Close() error {
+	return c.archiver.Close()
+}
+
+func (c *Client) GetQuotes(ctx context.Context, symbol string, last int) (
+	_ []finance.Quote, err error) {
+	ctx, span := tracer.Start(ctx, "cache.GetQuotes", trace.WithAttributes(
+		attribute.String("stonks.symbol", symbol),
+		attribute.Int("stonks.last", last)))
+	defer func() { tracing.End(span, err, history.ErrNotFound) }()
+
+	symbol = strings.ToLower(symbol)
+	if last < 1 {
+		last = 1
+	}
+
+	if quotes, ok := c.lookup(symbol, last); ok {
+		c.metrics.Hits.Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return quotes, nil
+	}
+	c.metrics.Misses.Inc()
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	fetch := last
+	if fetch < c.depth {
+		fetch = c.depth
+	}
+
+	gen := c.generation(symbol)
+	quotes, err := c.provider.GetQuotes(ctx, symbol, fetch)
+	if err != nil {
+		return nil, err
+	}
+	c.store(symbol, gen, quotes, fetch)
+
+	if len(quotes) > last {
+		quotes = quotes[:last]
+	}
+
+	return quotes, nil
+}
+
+func (c *Client) GetQuotesBatch(ctx context.Context, symbols []string,
+	last int) (_ finance.QuoteBatch, err error) {
+	ctx, span := tracer.Start(ctx, "cache.GetQuotesBatch", trace.WithAttributes(
+		attribute.StringSlice("stonks.symbols", symbols),
+		attribute.Int("stonks.last", last)))
+	defer func() { tracing.End(span, err, history.ErrNotFound) }()
+
+	if last < 1 {
+		last = 1
+	}
+
+	batch := make(finance.QuoteBatch)
+	misses := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.ToLower(symbol)
+		quotes, ok := c.lookup(symbol, last)
+		if !ok {
+			misses = append(misses, symbol)
+			continue
+		}
+		batch[symbol] = quotes
+	}
+	c.metrics.Hits.Add(float64(len(symbols) - len(misses)))
+	c.metrics.Misses.Add(float64(len(misses)))
+	span.SetAttributes(attribute.Int("cache.misses", len(misses)))
+
+	if len(misses) > 0 {
+		fetch := last
+		if fetch < c.depth {
+			fetch = c.depth
+		}
+
+		gens := make(map[string]uint64, len(misses))
+		for _, symbol := range misses {
+			gens[symbol] = c.generation(symbol)
+		}
+
+		fetched, err := c.provider.GetQuotesBatch(ctx, misses, fetch)
+		if err != nil && err != history.ErrNotFound {
+			return nil, err
+		}
+
+		for _, symbol := range misses {
+			quotes, ok := fetched[symbol]
+			if !ok || len(quotes) == 0 {
+				continue
+			}
+			c.store(symbol, gens[symbol], quotes, fetch)
+			if len(quotes) > last {
+				quotes = quotes[:last]
+			}
+			batch[symbol] = quotes
+		}
+	}
+
+	if len(batch) == 0 {
+		return nil, history.ErrNotFound
+	}
+
+	return batch, nil
+}
+
+// SetQuotes passes the quotes to the wrapped archiver and, once it accepts
+// them, adds them to the cached quotes of their symbols.
+func (c *Client) SetQuotes(ctx context.Context, quotes []finance.Quote) error {
+	if err := c.archiver.SetQuotes(ctx, quotes); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, q := range quotes {
+		symbol := strings.ToLower(q.Symbol)
+		q.Symbol = symbol
+		c.generations[symbol]++
+
+		e, ok := c.entries[symbol]
+		if !ok {
+			continue
+		}
+
+		// The archiver keeps one quote per symbol and time.
+		i, ok := searchTime(e.quotes, q.Time)
+		if ok {
+			e.quotes[i].Price = q.Price
+			continue
+		}
+		// An incomplete entry can't tell whether older quotes are missing
+		// between its oldest quote and q.
+		if i == len(e.quotes) && !e.complete {
+			continue
+		}
+
+		e.quotes = append(e.quotes, finance.Quote{})
+		copy(e.quotes[i+1:], e.quotes[i:])
+		e.quotes[i] = q
+		if len(e.quotes) > c.depth {
+			e.quotes = e.quotes[:c.depth]
+			e.complete = false
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) generation(symbol string) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.generations[symbol]
+}
+
+// lookup returns a copy of the last cached quotes for the symbol and true if
+// the cache can answer the request by itself.
+func (c *Client) lookup(symbol string, last int) ([]finance.Quote, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[symbol]
+	if !ok || (last > len(e.quotes) && !e.complete) {
+		return nil, false
+	}
+
+	if last > len(e.quotes) {
+		last = len(e.quotes)
+	}
+	out := make([]finance.Quote, last)
+	copy(out, e.quotes)
+
+	return out, true
+}
+
+// store caches the quotes the backend returned when asked for the last
+// requested quotes, unless a write for the symbol happened since gen. Symbols
+// without quotes aren't cached so unknown symbols can't grow the cache.
+func (c *Client) store(symbol string, gen uint64, quotes []finance.Quote,
+	requested int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[symbol] != gen {
+		return
+	}
+
+	e := &entry{complete: len(quotes) < requested}
+	if len(quotes) > c.depth {
+		quotes = quotes[:c.depth]
+		e.complete = false
+	}
+	e.quotes = make([]finance.Quote, len(quotes))
+	copy(e.quotes, quotes)
+
+	c.entries[symbol] = e
+}
+
+// GetQuotesPage passes through to the wrapped provider, if it's a
+// history.Pager, since deep pages are rarely requested twice.
+func (c *Client) GetQuotesPage(ctx context.Context, symbol string,
+	after *history.Cursor, limit int) (history.Page, error) {
+	p, ok := c.provider.(history.Pager)
+	if !ok {
+		return history.Page{}, history.ErrPagingUnsupported
+	}
+
+	return p.GetQuotesPage(ctx, symbol, after, limit)
+}
+
+// WalkQuotes passes through to the wrapped provider, if it's a
+// history.Walker, since walks read more than the cache holds.
+func (c *Client) WalkQuotes(ctx context.Context, f history.Filter,
+	fn func(finance.Quote) error) error {
+	w, ok := c.provider.(history.Walker)
+	if !ok {
+		return history.ErrWalkingUnsupported
+	}
+
+	return w.WalkQuotes(ctx, f, fn)
+}
+
+// searchTime returns the index of the quote at time t in quotes, which are
+// ordered newest first, and true, or the index to insert one at and false.
+func searchTime(quotes []finance.Quote, t time.Time) (int, bool) {
+	i := sort.Search(len(quotes), func(i int) bool {
+		return !quotes[i].Time.After(t)
+	})
+
+	return i, i < len(quotes) && quotes[i].Time.Equal(t)
+}
+
+func New(p history.Provider, a history.Archiver, options ...Option) (
+	*Client, error) {
+	switch {
+	case p == nil:
+		return nil, ErrNilProvider
+	case a == nil:
+		return nil, ErrNilArchiver
+	}
+
+	c := &Client{
+		provider:    p,
+		archiver:    a,
+		depth:       DefaultDepth,
+		entries:     make(map[string]*entry),
+		generations: make(map[string]uint64),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(c)
+		}
+	}
+
+	var err error
+	c.metrics, err = metrics.NewCache(c.registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
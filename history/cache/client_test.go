@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/history/memory"
+)
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	m := memory.New()
+	_, err := New(nil, nil)
+	if err != ErrNilProvider {
+		t.Errorf("expected ErrNilProvider: %v", err)
+	}
+
+	_, err = New(m, nil)
+	if err != ErrNilArchiver {
+		t.Errorf("expected ErrNilArchiver: %v", err)
+	}
+
+	c, err := New(m, m, Depth(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.depth != 5 {
+		t.Errorf("expected depth 5; actual: %d", c.depth)
+	}
+}
+
+func TestGetQuotes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	backend := &countingProvider{Provider: memory.New()}
+	archiver := backend.Provider.(history.Archiver)
+	err := archiver.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+		{Price: 123.40, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(backend, archiver, Depth(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		last     int
+		calls    int
+		expected []finance.Quote
+	}{
+		{ // miss loads the cache
+			last:  1,
+			calls: 1,
+			expected: []finance.Quote{
+				{Price: 123.40, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+			},
+		},
+		{ // hit
+			last:  2,
+			calls: 1,
+			expected: []finance.Quote{
+				{Price: 123.40, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+			},
+		},
+		{ // deeper than the cache falls through
+			last:  3,
+			calls: 2,
+			expected: []finance.Quote{
+				{Price: 123.40, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+				{Price: 123.45, Symbol: "fb", Time: now},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		actual, err := c.GetQuotes(context.Background(), "FB", tc.last)
+		if err != nil {
+			t.Errorf("%d: get quotes: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%d: actual quotes not equal to expected", i)
+			t.Logf("expected: %#v", tc.expected)
+			t.Logf("actual:   %#v", actual)
+		}
+		if calls := backend.count(); calls != tc.calls {
+			t.Errorf("%d: expected %d backend calls; actual: %d", i,
+				tc.calls, calls)
+		}
+	}
+
+	_, err = c.GetQuotes(context.Background(), "blah", 1)
+	if err != history.ErrNotFound {
+		t.Errorf("expected ErrNotFound: %v", err)
+	}
+}
+
+func TestSetQuotesUpdatesCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	backend := &countingProvider{Provider: memory.New()}
+	c, err := New(backend, backend.Provider.(history.Archiver), Depth(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 234.56, Symbol: "goog", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load the cache; the backend holds the symbol's entire history.
+	_, err = c.GetQuotes(context.Background(), "goog", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 234.51, Symbol: "goog", Time: now.Add(time.Minute)},
+		{Price: 234.50, Symbol: "goog", Time: now.Add(2 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := c.GetQuotes(context.Background(), "goog", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []finance.Quote{
+		{Price: 234.50, Symbol: "goog", Time: now.Add(2 * time.Minute)},
+		{Price: 234.51, Symbol: "goog", Time: now.Add(time.Minute)},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual quotes not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+	if calls := backend.count(); calls != 1 {
+		t.Errorf("expected 1 backend call; actual: %d", calls)
+	}
+}
+
+func TestSetQuotesDuplicates(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	backend := memory.New()
+	c, err := New(backend, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetQuotes(context.Background(), "fb", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.30, Symbol: "FB", Time: now.Add(-time.Minute)},
+		{Price: 123.42, Symbol: "fb", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := c.GetQuotes(context.Background(), "fb", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []finance.Quote{
+		{Price: 123.42, Symbol: "fb", Time: now},
+		{Price: 123.30, Symbol: "fb", Time: now.Add(-time.Minute)},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual quotes not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestGetQuotesBatch(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	backend := &countingProvider{Provider: memory.New()}
+	archiver := backend.Provider.(history.Archiver)
+	err := archiver.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+		{Price: 234.56, Symbol: "goog", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(backend, archiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Warm the cache for one symbol so the batch mixes hits and misses.
+	_, err = c.GetQuotes(context.Background(), "fb", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := c.GetQuotesBatch(context.Background(),
+		[]string{"fb", "goog"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := finance.QuoteBatch{
+		"fb": {
+			{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+			{Price: 123.45, Symbol: "fb", Time: now},
+		},
+		"goog": {
+			{Price: 234.56, Symbol: "goog", Time: now},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual batch not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+	if calls := backend.count(); calls != 2 {
+		t.Errorf("expected 2 backend calls; actual: %d", calls)
+	}
+
+	_, err = c.GetQuotesBatch(context.Background(), []string{"fb", "goog"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := backend.count(); calls != 2 {
+		t.Errorf("expected cached batch; backend calls: %d", calls)
+	}
+}
+
+type countingProvider struct {
+	history.Provider
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingProvider) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls
+}
+
+func (c *countingProvider) GetQuotes(ctx context.Context, symbol string,
+	last int) ([]finance.Quote, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	return c.Provider.GetQuotes(ctx, symbol, last)
+}
+
+func (c *countingProvider) GetQuotesBatch(ctx context.Context,
+	symbols []string, last int) (finance.QuoteBatch, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	return c.Provider.GetQuotesBatch(ctx, symbols, last)
+}
@@ -0,0 +1,20 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+type Option func(*Client)
+
+func Depth(i int) Option {
+	return func(c *Client) {
+		if i > 0 {
+			c.depth = i
+		}
+	}
+}
+
+// Registerer registers the cache's metrics with r.
+func Registerer(r prometheus.Registerer) Option {
+	return func(c *Client) {
+		c.registerer = r
+	}
+}
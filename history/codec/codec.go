@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+var ErrUnknownFormat = fmt.Errorf("unknown format")
+
+// Encoder writes quotes to an underlying writer. Close flushes buffered
+// quotes but doesn't close the underlying writer.
+type Encoder interface {
+	Encode(q finance.Quote) error
+	io.Closer
+}
+
+// Decoder reads quotes until it returns io.EOF.
+type Decoder interface {
+	Decode() (finance.Quote, error)
+	io.Closer
+}
+
+// ParseFormat returns the format named s, ignoring case.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CSV, NDJSON, Parquet:
+		return f, nil
+	case "jsonl":
+		return NDJSON, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// FormatFromPath returns the format matching the file extension of path.
+func FormatFromPath(path string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+func NewEncoder(w io.Writer, f Format) (Encoder, error) {
+	switch f {
+	case CSV:
+		return newCSVEncoder(w)
+	case NDJSON:
+		return newNDJSONEncoder(w), nil
+	case Parquet:
+		return newParquetEncoder(w)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+}
+
+// NewDecoder returns a decoder for quotes in file. Parquet decoding seeks
+// through the file, so it doesn't work on pipes such as stdin.
+func NewDecoder(file *os.File, f Format) (Decoder, error) {
+	switch f {
+	case CSV:
+		return newCSVDecoder(file)
+	case NDJSON:
+		return newNDJSONDecoder(file), nil
+	case Parquet:
+		return newParquetDecoder(file)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+}
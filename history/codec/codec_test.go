@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path     string
+		expected Format
+		err      bool
+	}{
+		{path: "quotes.csv", expected: CSV},
+		{path: "quotes.NDJSON", expected: NDJSON},
+		{path: "quotes.jsonl", expected: NDJSON},
+		{path: "/tmp/quotes.parquet", expected: Parquet},
+		{path: "quotes.xlsx", err: true},
+		{path: "quotes", err: true},
+	}
+
+	for i, tc := range testCases {
+		actual, err := FormatFromPath(tc.path)
+		if tc.err {
+			if !errors.Is(err, ErrUnknownFormat) {
+				t.Errorf("%d: expected ErrUnknownFormat: %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if actual != tc.expected {
+			t.Errorf("%d: actual format: %q; expected: %q", i, actual,
+				tc.expected)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	expected := []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 234.56, Symbol: "goog", Time: now.Add(time.Nanosecond)},
+		{Price: 3296.16, Symbol: "amzn", Time: now.Add(time.Minute)},
+	}
+
+	for _, f := range []Format{CSV, NDJSON, Parquet} {
+		f := f
+		t.Run(string(f), func(t *testing.T) {
+			t.Parallel()
+
+			file, err := ioutil.TempFile("", "stonks-*."+string(f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = file.Close()
+				_ = os.Remove(file.Name())
+			}()
+
+			e, err := NewEncoder(file, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, q := range expected {
+				if err = e.Encode(q); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = e.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = file.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := NewDecoder(file, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = d.Close() }()
+
+			var actual []finance.Quote
+			for {
+				q, err := d.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				actual = append(actual, q)
+			}
+
+			if len(actual) != len(expected) {
+				t.Fatalf("actual quote count %d; expected: %d", len(actual),
+					len(expected))
+			}
+			for i := range actual {
+				if !actual[i].Time.Equal(expected[i].Time) {
+					t.Errorf("%d: actual time: %v; expected: %v", i,
+						actual[i].Time, expected[i].Time)
+				}
+				actual[i].Time = expected[i].Time
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Error("actual quotes not equal to expected")
+				t.Logf("expected: %#v", expected)
+				t.Logf("actual:   %#v", actual)
+			}
+		})
+	}
+}
+
+func TestCSVDecoderErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := newCSVDecoder(strings.NewReader("sym,price,when\n"))
+	if err == nil {
+		t.Error("expected a header error")
+	}
+
+	d, err := newCSVDecoder(strings.NewReader(
+		"symbol,price,time\nfb,blah,2021-05-07T19:31:05Z\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.Decode(); err == nil {
+		t.Error("expected a price error")
+	}
+}
@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+var csvHeader = []string{"symbol", "price", "time"}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Encode(q finance.Quote) error {
+	return e.w.Write([]string{
+		q.Symbol,
+		strconv.FormatFloat(q.Price, 'f', -1, 64),
+		q.Time.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w)}
+	if err := e.w.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("writing CSV header: %w", err)
+	}
+
+	return e, nil
+}
+
+type csvDecoder struct {
+	r      *csv.Reader
+	record int
+}
+
+func (d *csvDecoder) Close() error {
+	return nil
+}
+
+func (d *csvDecoder) Decode() (finance.Quote, error) {
+	var q finance.Quote
+
+	record, err := d.r.Read()
+	if err != nil {
+		return q, err
+	}
+	d.record++
+
+	q.Symbol = record[0]
+	q.Price, err = strconv.ParseFloat(record[1], 64)
+	if err != nil {
+		return q, fmt.Errorf("record %d: price: %w", d.record, err)
+	}
+	q.Time, err = time.Parse(time.RFC3339Nano, record[2])
+	if err != nil {
+		return q, fmt.Errorf("record %d: time: %w", d.record, err)
+	}
+
+	return q, nil
+}
+
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	d := &csvDecoder{r: csv.NewReader(r)}
+	d.r.FieldsPerRecord = len(csvHeader)
+	d.r.ReuseRecord = true
+
+	header, err := d.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	if !strings.EqualFold(strings.Join(header, ","),
+		strings.Join(csvHeader, ",")) {
+		return nil, fmt.Errorf("unexpected CSV header %q; expected %q",
+			header, csvHeader)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,45 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+type ndjsonEncoder struct {
+	e *json.Encoder
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) Encode(q finance.Quote) error {
+	q.Time = q.Time.UTC()
+
+	return e.e.Encode(q)
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	return &ndjsonEncoder{e: json.NewEncoder(w)}
+}
+
+type ndjsonDecoder struct {
+	d *json.Decoder
+}
+
+func (d *ndjsonDecoder) Close() error {
+	return nil
+}
+
+func (d *ndjsonDecoder) Decode() (finance.Quote, error) {
+	var q finance.Quote
+	err := d.d.Decode(&q)
+
+	return q, err
+}
+
+func newNDJSONDecoder(r io.Reader) *ndjsonDecoder {
+	return &ndjsonDecoder{d: json.NewDecoder(r)}
+}
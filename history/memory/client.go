@@ -3,22 +3,32 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
 	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/multierr"
 )
 
 var (
 	_ history.Archiver = (*Client)(nil)
+	_ history.Pager    = (*Client)(nil)
 	_ history.Provider = (*Client)(nil)
+	_ history.Walker   = (*Client)(nil)
 )
 
 type Client struct {
 	mu     sync.RWMutex
 	quotes map[string][]finance.Quote
+
+	archiveDuration prometheus.Observer
+	archiveErrors   prometheus.Counter
+	duplicates      prometheus.Counter
 }
 
 
@@ -62,48 +72,183 @@ func (c *Client) GetQuotesBatch(_ context.Context, symbols []string,
 	batch := make(finance.QuoteBatch)
 	for _, symbol := range symbols {
 		quotes, ok := c.quotes[strings.ToLower(symbol)]
-		if !ok {
-			return nil, history.ErrNotFound
-		}
-		if len(quotes) == 0 {
+		if !ok || len(quotes) == 0 {
 			continue
 		}
 
-		if last < 1 {
-			last = 1
+		n := last
+		if n < 1 {
+			n = 1
 		}
-		if len(quotes) < last {
-			last = len(quotes)
+		if len(quotes) < n {
+			n = len(quotes)
 		}
 
-		batch[symbol] = make([]finance.Quote, last)
+		batch[symbol] = make([]finance.Quote, n)
 		copy(batch[symbol], quotes)
 	}
 
+	if len(batch) == 0 {
+		return nil, history.ErrNotFound
+	}
+
 	return batch, nil
 }
 
-func (c *Client) SetQuotes(_ context.Context, quotes []finance.Quote) error {
+// GetQuotesPage pages through a symbol's quotes. A quote's ID is its position
+// counting from the symbol's oldest quote, which only changes if an older
+// quote is archived after it.
+func (c *Client) GetQuotesPage(_ context.Context, symbol string,
+	after *history.Cursor, limit int) (history.Page, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	symbol = strings.ToLower(symbol)
+	quotes := c.quotes[symbol]
+	if after == nil && len(quotes) == 0 {
+		return history.Page{}, history.ErrNotFound
+	}
+
+	if limit < 1 {
+		limit = 1
+	}
+
+	// quotes[i] has ID len(quotes)-i.
+	start := 0
+	if after != nil {
+		start = len(quotes) - int(after.ID) + 1
+		if start < 0 {
+			start = 0
+		}
+		if start > len(quotes) {
+			start = len(quotes)
+		}
+	}
+	end := start + limit
+	if end > len(quotes) {
+		end = len(quotes)
+	}
+
+	page := history.Page{Quotes: make([]finance.Quote, end-start)}
+	copy(page.Quotes, quotes[start:end])
+	if end < len(quotes) {
+		page.Next = &history.Cursor{
+			Symbol: symbol,
+			ID:     int64(len(quotes) - end + 1),
+		}
+	}
+
+	return page, nil
+}
+
+func (c *Client) SetQuotes(_ context.Context, quotes []finance.Quote) (
+	err error) {
+	start := time.Now()
+	defer func() {
+		c.archiveDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			c.archiveErrors.Inc()
+		}
+	}()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	var err error
+	duplicates := 0
 	for _, quote := range quotes {
 		symbol := strings.ToLower(quote.Symbol)
+		quote.Symbol = symbol
 		quotes, ok := c.quotes[symbol]
 		if !ok {
 			multierr.AppendInto(&err, fmt.Errorf("symbol %q not found", quote.Symbol))
 			continue
 		}
 
-		c.quotes[symbol] = append([]finance.Quote{quote}, quotes...)
+		// Like the SQLite client, a quote at a stored quote's time replaces
+		// its price rather than adding a row.
+		i, ok := searchTime(quotes, quote.Time)
+		if ok {
+			if quotes[i].Price == quote.Price {
+				duplicates++
+			}
+			quotes[i].Price = quote.Price
+			continue
+		}
+
+		quotes = append(quotes, finance.Quote{})
+		copy(quotes[i+1:], quotes[i:])
+		quotes[i] = quote
+		c.quotes[symbol] = quotes
 	}
+	c.duplicates.Add(float64(duplicates))
 
 	return err
 }
 
+func (c *Client) WalkQuotes(ctx context.Context, f history.Filter,
+	fn func(finance.Quote) error) error {
+	c.mu.RLock()
+	var quotes []finance.Quote
+	for _, symbolQuotes := range c.quotes {
+		// Quotes are stored newest first.
+		for i := len(symbolQuotes) - 1; i >= 0; i-- {
+			if f.Match(symbolQuotes[i]) {
+				quotes = append(quotes, symbolQuotes[i])
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if quotes[i].Time.Equal(quotes[j].Time) {
+			return quotes[i].Symbol < quotes[j].Symbol
+		}
+		return quotes[i].Time.Before(quotes[j].Time)
+	})
+
+	for _, q := range quotes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchTime returns the index of the quote at time t in quotes, which are
+// ordered newest first, and true, or the index to insert one at and false.
+func searchTime(quotes []finance.Quote, t time.Time) (int, bool) {
+	i := sort.Search(len(quotes), func(i int) bool {
+		return !quotes[i].Time.After(t)
+	})
+
+	return i, i < len(quotes) && quotes[i].Time.Equal(t)
+}
+
+// instrument creates the client's metrics and registers them with r.
+func (c *Client) instrument(r prometheus.Registerer) error {
+	duplicates, err := metrics.NewDuplicateQuotes(r)
+	if err != nil {
+		return err
+	}
+	storage, err := metrics.NewStorage(r)
+	if err != nil {
+		return err
+	}
+
+	c.duplicates = duplicates.WithLabelValues("memory")
+	c.archiveDuration = storage.ArchiveDuration.WithLabelValues("memory")
+	c.archiveErrors = storage.ArchiveErrors.WithLabelValues("memory")
+
+	return nil
+}
+
 func New(options ...Option) *Client {
 	c := &Client{quotes: make(map[string][]finance.Quote)}
+	_ = c.instrument(nil)
 
 	for _, symbol := range finance.DefaultSymbols {
 		c.quotes[strings.ToLower(symbol)] = []finance.Quote{}
@@ -5,8 +5,10 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
 )
 
 func TestNewClient(t *testing.T) {
@@ -45,6 +47,7 @@ func TestNewClientOptions(t *testing.T) {
 func TestGetQuotes(t *testing.T) {
 	t.Parallel()
 
+	now := time.Now()
 	testCases := []struct {
 		quotes   []finance.Quote
 		symbol   string
@@ -53,13 +56,13 @@ func TestGetQuotes(t *testing.T) {
 	}{
 		{
 			quotes: []finance.Quote{
-				{Price: 123.45, Symbol: "fb"},
-				{Price: 123.42, Symbol: "fb"},
+				{Price: 123.45, Symbol: "fb", Time: now},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
 			},
 			symbol: "fb",
 			last:   0,
 			expected: []finance.Quote{
-				{Price: 123.42, Symbol: "fb"},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
 			},
 		},
 	}
@@ -90,6 +93,7 @@ func TestGetQuotes(t *testing.T) {
 func TestGetQuotesBatch(t *testing.T) {
 	t.Parallel()
 
+	now := time.Now()
 	testCases := []struct {
 		quotes   []finance.Quote
 		symbols  []string
@@ -98,18 +102,18 @@ func TestGetQuotesBatch(t *testing.T) {
 	}{
 		{
 			quotes: []finance.Quote{
-				{Price: 123.45, Symbol: "fb"},
-				{Price: 123.42, Symbol: "fb"},
-				{Price: 234.56, Symbol: "goog"},
+				{Price: 123.45, Symbol: "fb", Time: now},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+				{Price: 234.56, Symbol: "goog", Time: now},
 			},
 			symbols: []string{"fb", "goog"},
 			last:    0,
 			expected: finance.QuoteBatch{
 				"fb": {
-					{Price: 123.42, Symbol: "fb"},
+					{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
 				},
 				"goog": {
-					{Price: 234.56, Symbol: "goog"},
+					{Price: 234.56, Symbol: "goog", Time: now},
 				},
 			},
 		},
@@ -137,3 +141,106 @@ func TestGetQuotesBatch(t *testing.T) {
 		}
 	}
 }
+
+func TestSetQuotesDuplicates(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	c := New()
+
+	err := c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.42, Symbol: "fb", Time: now},
+		{Price: 123.40, Symbol: "fb", Time: now.Add(time.Minute)},
+		// Older quotes are kept in time order.
+		{Price: 123.30, Symbol: "FB", Time: now.Add(-time.Minute)},
+		{Price: 123.42, Symbol: "fb", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := c.GetQuotes(context.Background(), "fb", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []finance.Quote{
+		{Price: 123.40, Symbol: "fb", Time: now.Add(time.Minute)},
+		{Price: 123.42, Symbol: "fb", Time: now},
+		{Price: 123.30, Symbol: "fb", Time: now.Add(-time.Minute)},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual quotes not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestGetQuotesPage(t *testing.T) {
+	t.Parallel()
+
+	c := New()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	var quotes []finance.Quote
+	for i := 0; i < 5; i++ {
+		quotes = append(quotes, finance.Quote{
+			Price:  float64(100 + i),
+			Symbol: "fb",
+			Time:   now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	if err := c.SetQuotes(ctx, quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetQuotesPage(ctx, "goog", nil, 2); err != history.ErrNotFound {
+		t.Errorf("actual error: %v; expected: %v", err, history.ErrNotFound)
+	}
+
+	var (
+		actual []float64
+		after  *history.Cursor
+		pages  int
+	)
+	for {
+		page, err := c.GetQuotesPage(ctx, "FB", after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range page.Quotes {
+			actual = append(actual, q.Price)
+		}
+		pages++
+
+		if pages == 1 {
+			// Newer quotes don't shift the pages that follow.
+			err = c.SetQuotes(ctx, []finance.Quote{
+				{Price: 105, Symbol: "fb", Time: now.Add(time.Hour)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if page.Next == nil {
+			break
+		}
+		if pages > 3 {
+			t.Fatal("too many pages")
+		}
+		after = page.Next
+	}
+
+	expected := []float64{104, 103, 102, 101, 100}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual prices not equal to expected")
+		t.Logf("expected: %v", expected)
+		t.Logf("actual:   %v", actual)
+	}
+	if pages != 3 {
+		t.Errorf("actual pages: %d; expected: 3", pages)
+	}
+}
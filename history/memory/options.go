@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/cry0genic/go-stocks/finance"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Option func(*Client)
@@ -19,3 +20,11 @@ func Symbols(symbols []string) Option {
 		}
 	}
 }
+
+// Registerer registers the client's metrics with r. They stay unregistered
+// if r holds conflicting metrics.
+func Registerer(r prometheus.Registerer) Option {
+	return func(c *Client) {
+		_ = c.instrument(r)
+	}
+}
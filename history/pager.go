@@ -0,0 +1,84 @@
+package history
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+var (
+	ErrInvalidCursor     = fmt.Errorf("invalid cursor")
+	ErrPagingUnsupported = fmt.Errorf("paging unsupported")
+)
+
+// Cursor is a position in a symbol's quote history. Time and ID order the
+// symbol's quotes and mean nothing outside the Pager that issued them.
+type Cursor struct {
+	Symbol string
+	Time   time.Time
+	ID     int64
+}
+
+// String returns the cursor's opaque, URL-safe encoding.
+func (c Cursor) String() string {
+	var nanos int64
+	if !c.Time.IsZero() {
+		nanos = c.Time.UnixNano()
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Symbol + ":" +
+		strconv.FormatInt(nanos, 10) + ":" + strconv.FormatInt(c.ID, 10)))
+}
+
+// ParseCursor decodes a cursor returned by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	i := strings.LastIndexByte(string(b), ':')
+	if i < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(b[i+1:]), 10, 64)
+	if err != nil || id < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	j := strings.LastIndexByte(string(b[:i]), ':')
+	if j < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(string(b[j+1:i]), 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	c := Cursor{Symbol: string(b[:j]), ID: id}
+	if nanos != 0 {
+		c.Time = time.Unix(0, nanos).UTC()
+	}
+
+	return c, nil
+}
+
+// Page is a page of a symbol's quotes, newest first. Next is nil on the last
+// page.
+type Page struct {
+	Quotes []finance.Quote
+	Next   *Cursor
+}
+
+// Pager pages through a symbol's quote history, newest first, starting just
+// past after, or at the newest quote if after is nil. It returns ErrNotFound
+// if the symbol has no quotes.
+type Pager interface {
+	GetQuotesPage(ctx context.Context, symbol string, after *Cursor,
+		limit int) (Page, error)
+}
@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+)
+
+const (
+	insertAPIKey = `
+INSERT INTO api_keys (id, name, scopes, hash, created)
+  VALUES (?, ?, ?, ?, ?)`
+
+	revokeAPIKey = `
+UPDATE api_keys
+  SET revoked = ?
+  WHERE id = ? AND revoked IS NULL`
+
+	selectAPIKeyByHash = `
+SELECT id, name, scopes, created, revoked
+  FROM api_keys
+  WHERE hash = ?`
+
+	selectAPIKeyByID = `
+SELECT id, name, scopes, created, revoked
+  FROM api_keys
+  WHERE id = ?`
+
+	selectAPIKeys = `
+SELECT id, name, scopes, created, revoked
+  FROM api_keys
+  ORDER BY created, id`
+)
+
+var _ auth.Store = (*Client)(nil)
+
+func (c *Client) APIKeyByHash(ctx context.Context, hash []byte) (
+	auth.Key, error) {
+	k, err := scanAPIKey(c.db.QueryRowContext(ctx, selectAPIKeyByHash, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Key{}, auth.ErrUnknownKey
+	}
+
+	return k, err
+}
+
+func (c *Client) APIKeys(ctx context.Context) ([]auth.Key, error) {
+	rows, err := c.db.QueryContext(ctx, selectAPIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("select API keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys []auth.Key
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (c *Client) CreateAPIKey(ctx context.Context, k auth.Key,
+	hash []byte) error {
+	scopes := make([]string, len(k.Scopes))
+	for i, scope := range k.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	_, err := c.db.ExecContext(ctx, insertAPIKey, k.ID, k.Name,
+		strings.Join(scopes, ","), hash, k.Created.UTC())
+	if err != nil {
+		return fmt.Errorf("inserting API key: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes the key with the given ID. Revoking a revoked key is
+// a no-op.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	res, err := c.db.ExecContext(ctx, revokeAPIKey, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("revoking API key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+
+	_, err = scanAPIKey(c.db.QueryRowContext(ctx, selectAPIKeyByID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.ErrUnknownKey
+	}
+
+	return err
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (
+	auth.Key, error) {
+	var (
+		k       auth.Key
+		scopes  string
+		revoked sql.NullTime
+	)
+	err := row.Scan(&k.ID, &k.Name, &scopes, &k.Created, &revoked)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return k, err
+		}
+		return k, fmt.Errorf("row scan: %w", err)
+	}
+
+	k.Created = k.Created.UTC()
+	if revoked.Valid {
+		k.Revoked = revoked.Time.UTC()
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			k.Scopes = append(k.Scopes, auth.Scope(scope))
+		}
+	}
+
+	return k, nil
+}
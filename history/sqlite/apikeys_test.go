@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cry0genic/go-stocks/auth"
+)
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	ctx := context.Background()
+	token, expected, err := auth.Generate("dashboards",
+		[]auth.Scope{auth.ScopeRead, auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.CreateAPIKey(ctx, expected, auth.Hash(token)); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := c.APIKeyByHash(ctx, auth.Hash(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.Created.Equal(expected.Created) {
+		t.Errorf("actual created: %v; expected: %v", actual.Created,
+			expected.Created)
+	}
+	actual.Created = expected.Created
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual key not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+
+	_, err = c.APIKeyByHash(ctx, auth.Hash(token+"x"))
+	if !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey: %v", err)
+	}
+
+	if err = c.RevokeAPIKey(ctx, expected.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.RevokeAPIKey(ctx, expected.ID); err != nil {
+		t.Errorf("revoking a revoked key: %v", err)
+	}
+	if err = c.RevokeAPIKey(ctx, "blah"); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey: %v", err)
+	}
+
+	keys, err := c.APIKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != expected.ID || keys[0].Active() {
+		t.Errorf("expected one revoked key: %#v", keys)
+	}
+}
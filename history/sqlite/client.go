@@ -4,22 +4,41 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"math"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
 	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/tracing"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
 )
 
 const (
+	DefaultBusyTimeout      = 5 * time.Second
 	DefaultConnsMaxLifetime = -1
-	DefaultDatabaseFile = "stonks.sqlite"
-	DefaultMaxIdleConns = 2
+	DefaultDatabaseFile     = "stonks.sqlite"
+	DefaultJournalMode      = "WAL"
+	DefaultMaxIdleConns     = 2
+
+	// batchSize is the number of symbols a batch select reads. Larger
+	// batches take several selects, keeping within SQLite's compound
+	// select limit with a single prepared statement.
+	batchSize = 16
+
+	// metricsQueryTimeout bounds the queries behind the storage gauges, so a
+	// busy database can't stall a scrape.
+	metricsQueryTimeout = 5 * time.Second
 
 	createQuotesTable = `
-CREATE TABLE "quotes"
+CREATE TABLE IF NOT EXISTS "quotes"
 (
 	id integer not null
 		constraint quotes_pk
@@ -29,86 +48,292 @@ CREATE TABLE "quotes"
 	datetime timestamp not null
 )`
 
+	createQuotesSymbolIndex = `
+CREATE INDEX IF NOT EXISTS quotes_symbol_id_idx
+  ON quotes (symbol, id)`
+
+	deleteDuplicateQuotes = `
+DELETE FROM quotes
+  WHERE id NOT IN (
+    SELECT MIN(id)
+      FROM quotes
+      GROUP BY symbol, datetime
+  )`
+
+	createQuotesSymbolDatetimeIndex = `
+CREATE UNIQUE INDEX IF NOT EXISTS quotes_symbol_datetime_uindex
+  ON quotes (symbol, datetime)`
+
+	// insertQuote leaves a quote matching a stored quote's symbol, time and
+	// price alone, affecting no rows, and corrects the price otherwise.
 	insertQuote = `
 INSERT INTO quotes (symbol, price, datetime)
-  VALUES (?, ?, ?)`
+  VALUES (?, ?, ?)
+  ON CONFLICT (symbol, datetime) DO UPDATE
+    SET price = excluded.price
+    WHERE price != excluded.price`
+
+	createAPIKeysTable = `
+CREATE TABLE IF NOT EXISTS "api_keys"
+(
+	id text not null
+		constraint api_keys_pk
+			primary key,
+	name text not null,
+	scopes text not null,
+	hash blob not null
+		constraint api_keys_hash_uindex
+			unique,
+	created timestamp not null,
+	revoked timestamp
+)`
 
+	// The latest quotes are the newest by time, not by arrival, so history
+	// imported after polling doesn't pass for the latest quotes. Reads walk
+	// quotes_symbol_datetime_uindex, whose rows end in the ID.
 	selectQuotes = `
 SELECT symbol, price, datetime
   FROM quotes
   WHERE symbol = ?
-  ORDER BY id DESC
+  ORDER BY datetime DESC, id DESC
+  LIMIT ?`
+
+	// selectQuotesPage seeks quotes_symbol_datetime_uindex past the cursor's
+	// time and ID, so deep pages cost no more than the first.
+	selectQuotesPage = `
+SELECT id, symbol, price, datetime
+  FROM quotes
+  WHERE symbol = ? AND (datetime, id) < (?, ?)
+  ORDER BY datetime DESC, id DESC
   LIMIT ?`
 
-	
+	// selectQuotesBatch is built from batchSize selectQuotesBatchSymbol so
+	// each symbol's rows come from a bounded walk of
+	// quotes_symbol_datetime_uindex rather than from ranking every row of the
+	// requested symbols.
 	selectQuotesBatch = `
-WITH summary AS (
-  SELECT q.symbol, q.price, q.datetime, ROW_NUMBER()
-    OVER(PARTITION BY q.symbol
-    ORDER BY q.id DESC) AS rank
-  FROM quotes q
-)
-SELECT s.*
-FROM summary s
-WHERE symbol IN (XXX)
-  AND s.rank <= ?`
+SELECT symbol, price, datetime
+FROM (XXX)
+ORDER BY symbol, datetime DESC, id DESC`
+
+	selectQuotesWalk = `
+SELECT symbol, price, datetime
+  FROM quotes
+  WHERE 1 = 1XXX
+  ORDER BY datetime, symbol`
+
+	selectQuotesBatchSymbol = `
+SELECT * FROM (
+  SELECT id, symbol, price, datetime
+    FROM quotes
+    WHERE symbol = ?
+    ORDER BY datetime DESC, id DESC
+    LIMIT ?
+)`
 )
 
 var (
+	tracer = otel.Tracer("github.com/cry0genic/go-stocks/history/sqlite")
+
 	_ history.Archiver = (*Client)(nil)
+	_ history.Pager    = (*Client)(nil)
 	_ history.Provider = (*Client)(nil)
+	_ history.Walker   = (*Client)(nil)
+
+	// migrations bring the schema up to date. The database's user_version
+	// records how many of them have been applied.
+	migrations = []string{
+		createQuotesTable,
+		createQuotesSymbolIndex,
+		deleteDuplicateQuotes,
+		createQuotesSymbolDatetimeIndex,
+		createAPIKeysTable,
+	}
 )
 
 type Client struct {
 	db               *sql.DB
 	file             string
+	busyTimeout      time.Duration
+	journalMode      string
 	maxIdleConns     int
 	connsMaxLifetime time.Duration
 	symbols          map[string]struct{}
+	registerer       prometheus.Registerer
+
+	archiveDuration prometheus.Observer
+	archiveErrors   prometheus.Counter
+	duplicates      prometheus.Counter
+
+	// rowCount is the number of quotes counted at startup plus those the
+	// client inserted since.
+	rowCount int64
+
+	batchStmt  *sql.Stmt
+	insertStmt *sql.Stmt
+	pageStmt   *sql.Stmt
+	selectStmt *sql.Stmt
 }
 
 // initialize the database file.
 func (c *Client) initialize() error {
-	// TODO: Add proper support for data migrations.
-	err := os.Remove(c.file)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("removing %q: %w", c.file, err)
+	var err error
+	// Immediate transactions take the write lock when they begin, so a
+	// process waits out the busy timeout rather than failing to upgrade its
+	// lock while another process, such as a concurrent stonks serve and
+	// stonks poll, migrates or writes.
+	dsn := fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_txlock=immediate",
+		c.file, c.busyTimeout.Milliseconds(), c.journalMode)
+
+	c.db, err = sql.Open("sqlite3", dsn)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", c.file, err)
+	}
+
+	if err = c.migrate(); err != nil {
+		return err
 	}
 
-	c.db, err = sql.Open("sqlite3", c.file)
+	// Counting is a full scan of the quotes table, so it's done once.
+	err = c.db.QueryRow(`SELECT COUNT(*) FROM "quotes"`).Scan(&c.rowCount)
 	if err != nil {
-		return fmt.Errorf("open %q: %w", c.file, err)
+		return fmt.Errorf("counting quotes: %w", err)
+	}
+
+	c.insertStmt, err = c.db.Prepare(insertQuote)
+	if err != nil {
+		return fmt.Errorf("preparing insert: %w", err)
+	}
+
+	c.selectStmt, err = c.db.Prepare(selectQuotes)
+	if err != nil {
+		return fmt.Errorf("preparing select: %w", err)
 	}
 
-	_, err = c.db.Exec(createQuotesTable)
+	c.pageStmt, err = c.db.Prepare(selectQuotesPage)
 	if err != nil {
-		return fmt.Errorf("creating quotes table: %w", err)
+		return fmt.Errorf("preparing page select: %w", err)
+	}
+
+	selects := make([]string, batchSize)
+	for i := range selects {
+		selects[i] = selectQuotesBatchSymbol
+	}
+	c.batchStmt, err = c.db.Prepare(strings.Replace(selectQuotesBatch, "XXX",
+		strings.Join(selects, "\nUNION ALL"), 1))
+	if err != nil {
+		return fmt.Errorf("preparing batch select: %w", err)
 	}
 
 	return nil
 }
 
-func (c Client) Close() error {
-	if c.db == nil {
-		return nil
+// instrument creates the client's metrics and registers them.
+func (c *Client) instrument() error {
+	duplicates, err := metrics.NewDuplicateQuotes(c.registerer)
+	if err != nil {
+		return err
 	}
+	c.duplicates = duplicates.WithLabelValues("sqlite")
 
-	return c.db.Close()
+	storage, err := metrics.NewStorage(c.registerer)
+	if err != nil {
+		return err
+	}
+	c.archiveDuration = storage.ArchiveDuration.WithLabelValues("sqlite")
+	c.archiveErrors = storage.ArchiveErrors.WithLabelValues("sqlite")
+
+	return metrics.RegisterSQLite(c.registerer, c.rows, c.size)
 }
 
-func (c Client) GetQuotes(ctx context.Context, symbol string, last int) (
-	[]finance.Quote, error) {
-	stmt, err := c.db.Prepare(selectQuotes)
+// rows returns the number of quotes stored. Quotes other processes sharing
+// the database inserted since the client started aren't counted.
+func (c *Client) rows() float64 {
+	return float64(atomic.LoadInt64(&c.rowCount))
+}
+
+// size returns the database size in bytes, or 0 if it can't tell.
+func (c *Client) size() float64 {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsQueryTimeout)
+	defer cancel()
+
+	var pages, pageSize int64
+	err := c.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages)
 	if err != nil {
-		return nil, fmt.Errorf("selecting quotes: %w", err)
+		return 0
+	}
+	err = c.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize)
+	if err != nil {
+		return 0
+	}
+
+	return float64(pages * pageSize)
+}
+
+// migrate applies the migrations the database hasn't seen yet.
+func (c *Client) migrate() error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning migration: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var version int
+	err = tx.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	for ; version < len(migrations); version++ {
+		_, err = tx.Exec(migrations[version])
+		if err != nil {
+			return fmt.Errorf("migrating schema to version %d: %w", version+1,
+				err)
+		}
+	}
+
+	// PRAGMA statements don't accept bound parameters.
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version))
+	if err != nil {
+		return fmt.Errorf("writing schema version: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing migration: %w", err)
 	}
-	defer func() { _ = stmt.Close() }()
+
+	return nil
+}
+
+func (c *Client) Close() error {
+	if c.db == nil {
+		return nil
+	}
+
+	var err error
+	for _, stmt := range []*sql.Stmt{
+		c.batchStmt, c.insertStmt, c.pageStmt, c.selectStmt,
+	} {
+		if stmt != nil {
+			multierr.AppendInto(&err, stmt.Close())
+		}
+	}
+
+	return multierr.Append(err, c.db.Close())
+}
+
+func (c *Client) GetQuotes(ctx context.Context, symbol string, last int) (
+	_ []finance.Quote, err error) {
+	ctx, span := tracer.Start(ctx, "sqlite.GetQuotes", trace.WithAttributes(
+		attribute.String("stonks.symbol", symbol),
+		attribute.Int("stonks.last", last)))
+	defer func() { tracing.End(span, err, history.ErrNotFound) }()
 
 	if last < 1 {
 		last = 1
 	}
 
-	rows, err := stmt.Query(strings.ToLower(symbol), last)
+	rows, err := c.selectStmt.QueryContext(ctx, strings.ToLower(symbol), last)
 	if err != nil {
 		return nil, fmt.Errorf("select query: %w", err)
 	}
@@ -142,43 +367,136 @@ func (c Client) GetQuotes(ctx context.Context, symbol string, last int) (
 	return quotes, nil
 }
 
-func (c *Client) GetQuotesBatch(ctx context.Context, symbols []string,
-	last int) (finance.QuoteBatch, error) {
+func (c *Client) GetQuotesPage(ctx context.Context, symbol string,
+	after *history.Cursor, limit int) (_ history.Page, err error) {
+	ctx, span := tracer.Start(ctx, "sqlite.GetQuotesPage", trace.WithAttributes(
+		attribute.String("stonks.symbol", symbol),
+		attribute.Int("stonks.limit", limit)))
+	defer func() { tracing.End(span, err, history.ErrNotFound) }()
 
-	q := fmt.Sprintf("?%s", strings.Repeat(", ?", len(symbols)-1))
-	stmt, err := c.db.Prepare(strings.Replace(selectQuotesBatch, "XXX", q, 1))
+	symbol = strings.ToLower(symbol)
+	if limit < 1 {
+		limit = 1
+	}
+
+	// Past every stored time, as the stored times sort as text.
+	var before interface{} = "~"
+	beforeID := int64(math.MaxInt64)
+	if after != nil {
+		if after.Time.IsZero() {
+			return history.Page{}, history.ErrInvalidCursor
+		}
+		before, beforeID = after.Time.UTC(), after.ID
+	}
+
+	// One extra row reveals whether there's another page.
+	rows, err := c.pageStmt.QueryContext(ctx, symbol, before, beforeID, limit+1)
 	if err != nil {
-		return nil, fmt.Errorf("selecting quotes batch: %w", err)
+		return history.Page{}, fmt.Errorf("select query page: %w", err)
 	}
-	defer func() { _ = stmt.Close() }()
+	defer func() { _ = rows.Close() }()
+
+	var (
+		page = history.Page{Quotes: make([]finance.Quote, 0, limit)}
+		last history.Cursor
+	)
+	for rows.Next() {
+		var (
+			id int64
+			q  finance.Quote
+			t  time.Time
+		)
+		err = rows.Scan(&id, &q.Symbol, &q.Price, &t)
+		if err != nil {
+			return history.Page{}, fmt.Errorf("row scan: %w", err)
+		}
+		q.Time = t.UTC()
+
+		if len(page.Quotes) == limit {
+			page.Next = &last
+			break
+		}
+		page.Quotes = append(page.Quotes, q)
+		last = history.Cursor{Symbol: symbol, Time: q.Time, ID: id}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return history.Page{}, fmt.Errorf("rows error: %w", err)
+	}
+
+	if after == nil && len(page.Quotes) == 0 {
+		return history.Page{}, history.ErrNotFound
+	}
+
+	return page, nil
+}
+
+func (c *Client) GetQuotesBatch(ctx context.Context, symbols []string,
+	last int) (_ finance.QuoteBatch, err error) {
+	ctx, span := tracer.Start(ctx, "sqlite.GetQuotesBatch", trace.WithAttributes(
+		attribute.StringSlice("stonks.symbols", symbols),
+		attribute.Int("stonks.last", last)))
+	defer func() { tracing.End(span, err, history.ErrNotFound) }()
 
 	if last < 1 {
 		last = 1
 	}
 
-	lcSymbols := make([]interface{}, 0, len(symbols)+1)
+	var unique []string
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.ToLower(symbol)
+		if !seen[symbol] {
+			seen[symbol] = true
+			unique = append(unique, symbol)
+		}
+	}
+
+	batch := make(finance.QuoteBatch)
+	for start := 0; start < len(unique); start += batchSize {
+		end := start + batchSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+		if err = c.queryBatch(ctx, unique[start:end], last, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(batch) == 0 {
+		return nil, history.ErrNotFound
+	}
+
+	return batch, nil
+}
+
+// queryBatch adds the last quotes of up to batchSize symbols to batch. It
+// pads the statement's unused symbols with a limit of zero.
+func (c *Client) queryBatch(ctx context.Context, symbols []string, last int,
+	batch finance.QuoteBatch) error {
+	args := make([]interface{}, 0, 2*batchSize)
 	for _, symbol := range symbols {
-		lcSymbols = append(lcSymbols, strings.ToLower(symbol))
+		args = append(args, symbol, last)
+	}
+	for i := len(symbols); i < batchSize; i++ {
+		args = append(args, "", 0)
 	}
-	lcSymbols = append(lcSymbols, last)
 
-	rows, err := stmt.Query(lcSymbols...)
+	rows, err := c.batchStmt.QueryContext(ctx, args...)
 	if err != nil {
-		return nil, fmt.Errorf("select query batch: %w", err)
+		return fmt.Errorf("select query batch: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	batch := make(finance.QuoteBatch)
-
 	for rows.Next() {
 		var (
 			q finance.Quote
 			t time.Time
-			r int
 		)
-		err = rows.Scan(&q.Symbol, &q.Price, &t, &r)
+		err = rows.Scan(&q.Symbol, &q.Price, &t)
 		if err != nil {
-			return nil, fmt.Errorf("row scan: %w", err)
+			return fmt.Errorf("row scan: %w", err)
 		}
 		q.Time = t.UTC()
 
@@ -187,38 +505,128 @@ func (c *Client) GetQuotesBatch(ctx context.Context, symbols []string,
 
 	err = rows.Err()
 	if err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+		return fmt.Errorf("rows error: %w", err)
 	}
 
-	if len(batch) == 0 {
-		return nil, history.ErrNotFound
-	}
+	return nil
+}
 
-	return batch, nil
+// Ping reports whether the database is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
 }
 
-func (c Client) SetQuotes(ctx context.Context, quotes []finance.Quote) error {
+func (c *Client) SetQuotes(ctx context.Context, quotes []finance.Quote) (
+	err error) {
+	ctx, span := tracer.Start(ctx, "sqlite.SetQuotes", trace.WithAttributes(
+		attribute.Int("stonks.quotes", len(quotes))))
+	start := time.Now()
+	defer func() {
+		c.archiveDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			c.archiveErrors.Inc()
+		}
+		tracing.End(span, err)
+	}()
+
 	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("beginning transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	stmt, err := tx.PrepareContext(ctx, insertQuote)
+	stmt := tx.StmtContext(ctx, c.insertStmt)
+	defer func() { _ = stmt.Close() }()
+
+	// An upsert that updates a price leaves the last inserted row ID as is,
+	// telling inserts from updates.
+	var lastID int64
+	err = tx.QueryRowContext(ctx, "SELECT last_insert_rowid()").Scan(&lastID)
 	if err != nil {
-		return fmt.Errorf("preparing insert: %w", err)
+		return fmt.Errorf("reading last row ID: %w", err)
 	}
-	defer func() { _ = stmt.Close() }()
 
+	duplicates, inserted := 0, int64(0)
 	for _, q := range quotes {
-		_, err = stmt.Exec(strings.ToLower(q.Symbol), q.Price, q.Time.UTC())
+		res, err := stmt.ExecContext(ctx, strings.ToLower(q.Symbol), q.Price,
+			q.Time.UTC())
 		if err != nil {
 			return fmt.Errorf("inserting %v: %w", q, err)
 		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			duplicates++
+			continue
+		}
+		if id, err := res.LastInsertId(); err == nil && id != lastID {
+			inserted++
+			lastID = id
+		}
 	}
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("committing transaction: %w", err)
 	}
+	c.duplicates.Add(float64(duplicates))
+	atomic.AddInt64(&c.rowCount, inserted)
+
+	return nil
+}
+
+// WalkQuotes streams the quotes matching f to fn in time order, reading them
+// row by row rather than loading the whole range.
+func (c *Client) WalkQuotes(ctx context.Context, f history.Filter,
+	fn func(finance.Quote) error) (err error) {
+	ctx, span := tracer.Start(ctx, "sqlite.WalkQuotes", trace.WithAttributes(
+		attribute.StringSlice("stonks.symbols", f.Symbols)))
+	defer func() { tracing.End(span, err) }()
+
+	var (
+		where strings.Builder
+		args  []interface{}
+	)
+	if len(f.Symbols) > 0 {
+		where.WriteString(fmt.Sprintf("\n  AND symbol IN (?%s)",
+			strings.Repeat(", ?", len(f.Symbols)-1)))
+		for _, symbol := range f.Symbols {
+			args = append(args, strings.ToLower(symbol))
+		}
+	}
+	if !f.From.IsZero() {
+		where.WriteString("\n  AND datetime >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where.WriteString("\n  AND datetime < ?")
+		args = append(args, f.To.UTC())
+	}
+
+	rows, err := c.db.QueryContext(ctx,
+		strings.Replace(selectQuotesWalk, "XXX", where.String(), 1), args...)
+	if err != nil {
+		return fmt.Errorf("select query walk: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			q finance.Quote
+			t time.Time
+		)
+		err = rows.Scan(&q.Symbol, &q.Price, &t)
+		if err != nil {
+			return fmt.Errorf("row scan: %w", err)
+		}
+		q.Time = t.UTC()
+
+		if err = fn(q); err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
 
 	return nil
 }
@@ -226,8 +634,10 @@ func (c Client) SetQuotes(ctx context.Context, quotes []finance.Quote) error {
 func New(options ...Option) (*Client, error) {
 	c := &Client{
 		file:             DefaultDatabaseFile,
-		connsMaxLifetime: -1,
-		maxIdleConns:     2,
+		busyTimeout:      DefaultBusyTimeout,
+		journalMode:      DefaultJournalMode,
+		connsMaxLifetime: DefaultConnsMaxLifetime,
+		maxIdleConns:     DefaultMaxIdleConns,
 		symbols:          make(map[string]struct{}),
 	}
 
@@ -240,6 +650,12 @@ func New(options ...Option) (*Client, error) {
 	}
 
 	if err := c.initialize(); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	if err := c.instrument(); err != nil {
+		_ = c.Close()
 		return nil, err
 	}
 
@@ -2,13 +2,18 @@ package sqlite
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestGetQuotes(t *testing.T) {
@@ -21,15 +26,15 @@ func TestGetQuotes(t *testing.T) {
 		last     int
 		expected []finance.Quote
 	}{
-		{ 
+		{
 			quotes: []finance.Quote{
 				{Price: 123.45, Symbol: "fb", Time: now},
-				{Price: 123.42, Symbol: "fb", Time: now},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
 			},
 			symbol: "fb",
 			last:   0,
 			expected: []finance.Quote{
-				{Price: 123.42, Symbol: "fb", Time: now},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
 			},
 		},
 	}
@@ -93,13 +98,13 @@ func TestGetQuotesBatch(t *testing.T) {
 		last     int
 		expected finance.QuoteBatch
 	}{
-		{ 
+		{
 			quotes: []finance.Quote{
 				{Price: 123.45, Symbol: "fb", Time: now},
-				{Price: 123.42, Symbol: "fb", Time: now},
-				{Price: 123.40, Symbol: "fb", Time: now},
+				{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+				{Price: 123.40, Symbol: "fb", Time: now.Add(2 * time.Minute)},
 				{Price: 234.56, Symbol: "goog", Time: now},
-				{Price: 234.51, Symbol: "goog", Time: now},
+				{Price: 234.51, Symbol: "goog", Time: now.Add(time.Minute)},
 			},
 			symbols: []string{"fb", "goog"},
 			last:    2,
@@ -114,6 +119,15 @@ func TestGetQuotesBatch(t *testing.T) {
 				},
 			},
 		},
+		{
+			// More symbols than fit in a single select, and repeats.
+			symbols: append(manySymbols(600), "FB", "goog", "fb"),
+			last:    1,
+			expected: finance.QuoteBatch{
+				"fb":   {{Price: 123.40, Symbol: "fb"}},
+				"goog": {{Price: 234.51, Symbol: "goog"}},
+			},
+		},
 	}
 
 	dir, err := ioutil.TempDir("", "stonks")
@@ -169,3 +183,483 @@ func TestGetQuotesBatch(t *testing.T) {
 		}
 	}
 }
+
+// manySymbols returns n symbols without quotes.
+func manySymbols(n int) []string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("sym%d", i)
+	}
+
+	return symbols
+}
+
+func TestSetQuotesDuplicates(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	now := time.Now().UTC()
+	for _, quotes := range [][]finance.Quote{
+		{
+			{Price: 123.45, Symbol: "fb", Time: now},
+			{Price: 123.45, Symbol: "FB", Time: now},
+		},
+		{
+			{Price: 123.42, Symbol: "fb", Time: now},
+			{Price: 123.40, Symbol: "fb", Time: now.Add(time.Minute)},
+		},
+	} {
+		if err = c.SetQuotes(context.Background(), quotes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actual, err := c.GetQuotes(context.Background(), "fb", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []finance.Quote{
+		{Price: 123.40, Symbol: "fb", Time: now.Add(time.Minute)},
+		{Price: 123.42, Symbol: "fb", Time: now},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual quotes not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestWalkQuotes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// History imported after live polling is walked in time order too.
+	now := time.Now().UTC()
+	for _, quotes := range [][]finance.Quote{
+		{
+			{Price: 123.45, Symbol: "fb", Time: now},
+			{Price: 50.00, Symbol: "aapl", Time: now},
+		},
+		{
+			{Price: 120.00, Symbol: "fb", Time: now.Add(-2 * time.Minute)},
+			{Price: 121.00, Symbol: "fb", Time: now.Add(-time.Minute)},
+		},
+	} {
+		if err = c.SetQuotes(context.Background(), quotes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var actual []finance.Quote
+	err = c.WalkQuotes(context.Background(), history.Filter{},
+		func(q finance.Quote) error {
+			actual = append(actual, q)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []finance.Quote{
+		{Price: 120.00, Symbol: "fb", Time: now.Add(-2 * time.Minute)},
+		{Price: 121.00, Symbol: "fb", Time: now.Add(-time.Minute)},
+		{Price: 50.00, Symbol: "aapl", Time: now},
+		{Price: 123.45, Symbol: "fb", Time: now},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual quotes not equal to expected")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+func TestGetQuotesPage(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	var quotes []finance.Quote
+	for i := 0; i < 5; i++ {
+		quotes = append(quotes, finance.Quote{
+			Price:  float64(100 + i),
+			Symbol: "fb",
+			Time:   now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	if err := c.SetQuotes(ctx, quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetQuotesPage(ctx, "goog", nil, 2); err != history.ErrNotFound {
+		t.Errorf("actual error: %v; expected: %v", err, history.ErrNotFound)
+	}
+
+	var (
+		actual []float64
+		after  *history.Cursor
+		pages  int
+	)
+	for {
+		page, err := c.GetQuotesPage(ctx, "FB", after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range page.Quotes {
+			actual = append(actual, q.Price)
+		}
+		pages++
+
+		if pages == 1 {
+			// Newer quotes don't shift the pages that follow.
+			err = c.SetQuotes(ctx, []finance.Quote{
+				{Price: 105, Symbol: "fb", Time: now.Add(time.Hour)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if page.Next == nil {
+			break
+		}
+		if pages > 3 {
+			t.Fatal("too many pages")
+		}
+		after = page.Next
+	}
+
+	expected := []float64{104, 103, 102, 101, 100}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("actual prices not equal to expected")
+		t.Logf("expected: %v", expected)
+		t.Logf("actual:   %v", actual)
+	}
+	if pages != 3 {
+		t.Errorf("actual pages: %d; expected: 3", pages)
+	}
+}
+
+func TestImportedHistory(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	// The poller archives the latest quote before older history is imported.
+	err = c.SetQuotes(ctx, []finance.Quote{
+		{Price: 200, Symbol: "fb", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.SetQuotes(ctx, []finance.Quote{
+		{Price: 100, Symbol: "fb", Time: now.Add(-24 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := c.GetQuotes(ctx, "fb", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || quotes[0].Price != 200 {
+		t.Errorf("actual latest quote: %v; expected price: 200", quotes)
+	}
+
+	batch, err := c.GetQuotesBatch(ctx, []string{"fb"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := batch["fb"]; len(q) != 1 || q[0].Price != 200 {
+		t.Errorf("actual latest batch quote: %v; expected price: 200", q)
+	}
+
+	var (
+		actual []float64
+		after  *history.Cursor
+	)
+	for i := 0; i < 3; i++ {
+		page, err := c.GetQuotesPage(ctx, "fb", after, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range page.Quotes {
+			actual = append(actual, q.Price)
+		}
+		if page.Next == nil {
+			break
+		}
+		next, err := history.ParseCursor(page.Next.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		after = &next
+	}
+	expected := []float64{200, 100}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual prices: %v; expected: %v", actual, expected)
+	}
+}
+
+func TestNewKeepsHistory(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	file := filepath.Join(dir, DefaultDatabaseFile)
+	c, err := New(DatabaseFile(file), JournalMode("wal"),
+		BusyTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: time.Now().UTC()},
+	}
+	err = c.SetQuotes(context.Background(), expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = New(DatabaseFile(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	var version int
+	err = c.db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("expected schema version %d; actual: %d", len(migrations),
+			version)
+	}
+
+	actual, err := c.GetQuotes(context.Background(), "fb", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 1 || !actual[0].Time.Equal(expected[0].Time) {
+		t.Error("quotes did not survive reopening the database")
+		t.Logf("expected: %#v", expected)
+		t.Logf("actual:   %#v", actual)
+	}
+}
+
+const benchmarkRows = 1000000
+
+// benchmarkClient returns a client backed by a database holding benchmarkRows
+// quotes spread across the default symbols, inserted in poll order.
+func benchmarkClient(b *testing.B) *Client {
+	b.Helper()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = c.Close() })
+
+	start := time.Now().Add(-benchmarkRows * time.Minute)
+	quotes := make([]finance.Quote, 0, benchmarkRows)
+	for i := 0; i < benchmarkRows; i++ {
+		quotes = append(quotes, finance.Quote{
+			Price:  float64(i%1000) + 0.42,
+			Symbol: finance.DefaultSymbols[i%len(finance.DefaultSymbols)],
+			Time:   start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	if err = c.SetQuotes(context.Background(), quotes); err != nil {
+		b.Fatal(err)
+	}
+
+	return c
+}
+
+func BenchmarkGetQuotes(b *testing.B) {
+	c := benchmarkClient(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := c.GetQuotes(context.Background(), "goog", 10)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetQuotesBatch(b *testing.B) {
+	c := benchmarkClient(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := c.GetQuotesBatch(context.Background(),
+			finance.DefaultSymbols, 10)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "stonks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Logf("removing temp dir: %v", err)
+		}
+	}()
+
+	reg := prometheus.NewRegistry()
+	c, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)),
+		Registerer(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	now := time.Now().UTC()
+	err = c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := c.rows(); actual != 2 {
+		t.Errorf("actual rows: %v; expected: 2", actual)
+	}
+
+	// Price corrections update rows rather than adding them.
+	err = c.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.40, Symbol: "fb", Time: now.Add(time.Minute)},
+		{Price: 123.41, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+		{Price: 123.43, Symbol: "fb", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := c.rows(); actual != 3 {
+		t.Errorf("actual rows: %v; expected: 3", actual)
+	}
+
+	reopened, err := New(DatabaseFile(filepath.Join(dir, DefaultDatabaseFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if actual := reopened.rows(); actual != 3 {
+		t.Errorf("actual rows counted at startup: %v; expected: 3", actual)
+	}
+	if actual := c.size(); actual <= 0 {
+		t.Errorf("actual size: %v; expected a positive size", actual)
+	}
+	if actual := testutil.ToFloat64(c.duplicates); actual != 1 {
+		t.Errorf("actual duplicates: %v; expected: 1", actual)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	for _, name := range []string{
+		"archive_duration_seconds",
+		"duplicate_quotes_suppressed_total",
+		"sqlite_database_size_bytes",
+		"sqlite_quotes_rows",
+	} {
+		if !names[name] {
+			t.Errorf("%s not registered", name)
+		}
+	}
+}
@@ -1,9 +1,22 @@
 package sqlite
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type Option func(*Client)
 
+func BusyTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		if d >= 0 {
+			c.busyTimeout = d
+		}
+	}
+}
+
 func ConnMaxLifetime(d time.Duration) Option {
 	return func(c *Client) {
 		c.connsMaxLifetime = d
@@ -18,6 +31,14 @@ func DatabaseFile(f string) Option {
 	}
 }
 
+func JournalMode(mode string) Option {
+	return func(c *Client) {
+		if mode != "" {
+			c.journalMode = strings.ToUpper(mode)
+		}
+	}
+}
+
 func MaxIdleConnections(i int) Option {
 	return func(c *Client) {
 		c.maxIdleConns = i
@@ -33,3 +54,10 @@ func Symbols(symbols []string) Option {
 		}
 	}
 }
+
+// Registerer registers the client's metrics with r.
+func Registerer(r prometheus.Registerer) Option {
+	return func(c *Client) {
+		c.registerer = r
+	}
+}
@@ -0,0 +1,47 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+var ErrWalkingUnsupported = fmt.Errorf("walking unsupported")
+
+// Filter selects stored quotes. Its zero value selects every quote.
+type Filter struct {
+	Symbols []string
+	From    time.Time // inclusive; unbounded if zero
+	To      time.Time // exclusive; unbounded if zero
+}
+
+// Match reports whether the filter selects the quote.
+func (f Filter) Match(q finance.Quote) bool {
+	if !f.From.IsZero() && q.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !q.Time.Before(f.To) {
+		return false
+	}
+	if len(f.Symbols) == 0 {
+		return true
+	}
+
+	for _, symbol := range f.Symbols {
+		if strings.EqualFold(symbol, q.Symbol) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Walker streams stored quotes to fn, oldest first and then by symbol,
+// without holding them all in memory. Walking stops at the first error fn
+// returns.
+type Walker interface {
+	WalkQuotes(ctx context.Context, f Filter, fn func(finance.Quote) error) error
+}
@@ -2,87 +2,270 @@ package metrics
 
 import "github.com/prometheus/client_golang/prometheus"
 
-func init() {
-	prometheus.MustRegister(
-		ClientAPIRequests,
-		ClientDNSDuration,
-		ClientInFlightRequests,
-		ClientRequestDuration,
-		ClientTLSDuration,
-		ServerAPIRequests,
-		ServerInFlightRequests,
-		ServerRequestDuration,
-		ServerResponseBytes,
-	)
+// HTTPServer instruments the API server. Request metrics are labeled with
+// the response code, the request method and the matched route template.
+type HTTPServer struct {
+	InFlightRequests prometheus.Gauge
+	Requests         *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	ResponseBytes    *prometheus.HistogramVec
 }
 
-var ClientAPIRequests = prometheus.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "client_api_requests_total",
-		Help: "A counter for requests from the wrapped client.",
-	}, []string{},
-)
-
-var ClientDNSDuration = prometheus.NewHistogramVec(
-	prometheus.HistogramOpts{
-		Name:    "client_dns_duration_seconds",
-		Help:    "Trace DNS latency histogram.",
-		Buckets: prometheus.DefBuckets,
-	}, []string{},
-)
-
-var ClientInFlightRequests = prometheus.NewGauge(
-	prometheus.GaugeOpts{
-		Name: "client_in_flight_requests",
-		Help: "A gauge of in-flight requests for the wrapped client.",
-	},
-)
-
-var ClientRequestDuration = prometheus.NewHistogramVec(
-	prometheus.HistogramOpts{
-		Name:    "client_request_duration_seconds",
-		Help:    "A histogram of request latencies.",
-		Buckets: prometheus.DefBuckets,
-	}, []string{},
-)
-
-var ClientTLSDuration = prometheus.NewHistogramVec(
-	prometheus.HistogramOpts{
-		Name:    "client_tls_duration_seconds",
-		Help:    "Trace TLS latency histogram.",
-		Buckets: prometheus.DefBuckets,
-	}, []string{},
-)
-
-var ServerAPIRequests = prometheus.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "api_requests_total",
-		Help: "A counter for requests to the wrapped handler.",
-	},
-	[]string{},
-)
-
-var ServerInFlightRequests = prometheus.NewGauge(
-	prometheus.GaugeOpts{
-		Name: "server_in_flight_requests",
-		Help: "A gauge of requests currently being served by the wrapped handler.",
-	},
-)
-
-var ServerRequestDuration = prometheus.NewHistogramVec(
-	prometheus.HistogramOpts{
-		Name:    "request_duration_seconds",
-		Help:    "A histogram of latencies for requests.",
-		Buckets: prometheus.DefBuckets,
-	},
-	[]string{},
-)
-
-var ServerResponseBytes = prometheus.NewHistogramVec(
-	prometheus.HistogramOpts{
-		Name:    "response_size_bytes",
-		Help:    "A histogram of response sizes for requests.",
-		Buckets: prometheus.DefBuckets,
-	},
-	[]string{},
-)
+func NewHTTPServer(r prometheus.Registerer) (*HTTPServer, error) {
+	labels := []string{"code", "method", "route"}
+	m := &HTTPServer{
+		InFlightRequests: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "server_in_flight_requests",
+				Help: "A gauge of requests currently being served by the wrapped handler.",
+			},
+		),
+		Requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "api_requests_total",
+				Help: "A counter for requests to the wrapped handler.",
+			}, labels,
+		),
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "request_duration_seconds",
+				Help:    "A histogram of latencies for requests.",
+				Buckets: prometheus.DefBuckets,
+			}, labels,
+		),
+		ResponseBytes: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "response_size_bytes",
+				Help:    "A histogram of response sizes for requests.",
+				Buckets: prometheus.ExponentialBuckets(128, 4, 8),
+			}, labels,
+		),
+	}
+
+	var err error
+	if m.InFlightRequests, err = registerGauge(r, m.InFlightRequests); err != nil {
+		return nil, err
+	}
+	if m.Requests, err = registerCounterVec(r, m.Requests); err != nil {
+		return nil, err
+	}
+	if m.RequestDuration, err = registerHistogramVec(r, m.RequestDuration); err != nil {
+		return nil, err
+	}
+	if m.ResponseBytes, err = registerHistogramVec(r, m.ResponseBytes); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// HTTPClient instruments the IEX Cloud client. Request metrics are labeled
+// with the response code and the request method, and DNS and TLS latencies
+// with the traced event.
+type HTTPClient struct {
+	DNSDuration      *prometheus.HistogramVec
+	InFlightRequests prometheus.Gauge
+	Requests         *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	TLSDuration      *prometheus.HistogramVec
+}
+
+func NewHTTPClient(r prometheus.Registerer) (*HTTPClient, error) {
+	m := &HTTPClient{
+		DNSDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_dns_duration_seconds",
+				Help:    "Trace DNS latency histogram.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"event"},
+		),
+		InFlightRequests: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "client_in_flight_requests",
+				Help: "A gauge of in-flight requests for the wrapped client.",
+			},
+		),
+		Requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "client_api_requests_total",
+				Help: "A counter for requests from the wrapped client.",
+			}, []string{"code", "method"},
+		),
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_request_duration_seconds",
+				Help:    "A histogram of request latencies.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"code", "method"},
+		),
+		TLSDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "client_tls_duration_seconds",
+				Help:    "Trace TLS latency histogram.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"event"},
+		),
+	}
+
+	var err error
+	if m.DNSDuration, err = registerHistogramVec(r, m.DNSDuration); err != nil {
+		return nil, err
+	}
+	if m.InFlightRequests, err = registerGauge(r, m.InFlightRequests); err != nil {
+		return nil, err
+	}
+	if m.Requests, err = registerCounterVec(r, m.Requests); err != nil {
+		return nil, err
+	}
+	if m.RequestDuration, err = registerHistogramVec(r, m.RequestDuration); err != nil {
+		return nil, err
+	}
+	if m.TLSDuration, err = registerHistogramVec(r, m.TLSDuration); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// GRPCServer instruments the gRPC server.
+type GRPCServer struct {
+	Handled         *prometheus.CounterVec
+	HandlingSeconds *prometheus.HistogramVec
+	MsgSent         *prometheus.CounterVec
+}
+
+func NewGRPCServer(r prometheus.Registerer) (*GRPCServer, error) {
+	m := &GRPCServer{
+		Handled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_server_handled_total",
+				Help: "A counter of RPCs completed on the server, regardless of success or failure.",
+			}, []string{"grpc_type", "grpc_method", "grpc_code"},
+		),
+		HandlingSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "grpc_server_handling_seconds",
+				Help:    "A histogram of RPC latencies on the server.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"grpc_type", "grpc_method"},
+		),
+		MsgSent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_server_msg_sent_total",
+				Help: "A counter of stream messages sent by the server.",
+			}, []string{"grpc_type", "grpc_method"},
+		),
+	}
+
+	var err error
+	if m.Handled, err = registerCounterVec(r, m.Handled); err != nil {
+		return nil, err
+	}
+	if m.HandlingSeconds, err = registerHistogramVec(r, m.HandlingSeconds); err != nil {
+		return nil, err
+	}
+	if m.MsgSent, err = registerCounterVec(r, m.MsgSent); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Archiver instruments the buffered archiver.
+type Archiver struct {
+	DroppedQuotes prometheus.Counter
+	FlushDuration prometheus.Histogram
+	FlushedQuotes prometheus.Counter
+	QueueDepth    prometheus.Gauge
+}
+
+func NewArchiver(r prometheus.Registerer) (*Archiver, error) {
+	m := &Archiver{
+		DroppedQuotes: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "archiver_dropped_quotes_total",
+				Help: "A counter of quotes the buffered archiver dropped after repeated flush failures.",
+			},
+		),
+		FlushDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "archiver_flush_duration_seconds",
+				Help:    "A histogram of buffered archiver flush latencies.",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+		FlushedQuotes: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "archiver_flushed_quotes_total",
+				Help: "A counter of quotes flushed by the buffered archiver.",
+			},
+		),
+		QueueDepth: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "archiver_queue_depth",
+				Help: "A gauge of quotes waiting in the buffered archiver.",
+			},
+		),
+	}
+
+	var err error
+	if m.DroppedQuotes, err = registerCounter(r, m.DroppedQuotes); err != nil {
+		return nil, err
+	}
+	if m.FlushDuration, err = registerHistogram(r, m.FlushDuration); err != nil {
+		return nil, err
+	}
+	if m.FlushedQuotes, err = registerCounter(r, m.FlushedQuotes); err != nil {
+		return nil, err
+	}
+	if m.QueueDepth, err = registerGauge(r, m.QueueDepth); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Cache instruments the quote cache.
+type Cache struct {
+	Hits   prometheus.Counter
+	Misses prometheus.Counter
+}
+
+func NewCache(r prometheus.Registerer) (*Cache, error) {
+	m := &Cache{
+		Hits: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_hits_total",
+				Help: "A counter of symbol lookups served from the quote cache.",
+			},
+		),
+		Misses: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_misses_total",
+				Help: "A counter of symbol lookups that fell through to the backend.",
+			},
+		),
+	}
+
+	var err error
+	if m.Hits, err = registerCounter(r, m.Hits); err != nil {
+		return nil, err
+	}
+	if m.Misses, err = registerCounter(r, m.Misses); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// NewDuplicateQuotes returns the counter of quotes dropped for repeating a
+// stored quote, labeled with the dropping source.
+func NewDuplicateQuotes(r prometheus.Registerer) (*prometheus.CounterVec,
+	error) {
+	return registerCounterVec(r, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duplicate_quotes_suppressed_total",
+			Help: "A counter of quotes dropped for repeating a stored quote.",
+		}, []string{"source"},
+	))
+}
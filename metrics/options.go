@@ -1,26 +1,46 @@
 package metrics
 
 import (
-	"net/http"
+	"strings"
 	"time"
 )
 
-type Option func(*http.Server)
+type Option func(*Server)
+
+// BasicAuth requires requests to present the username and password with
+// basic auth.
+func BasicAuth(username, password string) Option {
+	return func(s *Server) {
+		s.username = username
+		s.password = password
+	}
+}
 
 func IdleTimeout(d time.Duration) Option {
-	return func(s *http.Server) {
-		s.IdleTimeout = d
+	return func(s *Server) {
+		s.idleTimeout = d
 	}
 }
 
 func ListenAddress(addr string) Option {
-	return func(s *http.Server) {
-		s.Addr = addr
+	return func(s *Server) {
+		if addr != "" {
+			s.listenAddr = addr
+		}
+	}
+}
+
+// Path sets the path metrics are served on, "/metrics" by default.
+func Path(p string) Option {
+	return func(s *Server) {
+		if strings.HasPrefix(p, "/") {
+			s.path = p
+		}
 	}
 }
 
 func ReadHeaderTimeout(d time.Duration) Option {
-	return func(s *http.Server) {
-		s.ReadHeaderTimeout = d
+	return func(s *Server) {
+		s.readHeaderTimeout = d
 	}
 }
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Poll instruments the poller. Quote metrics are labeled with the symbol.
+type Poll struct {
+	CycleDuration  prometheus.Histogram
+	Cycles         *prometheus.CounterVec
+	LastPrice      *prometheus.GaugeVec
+	QuotesArchived *prometheus.CounterVec
+	QuotesReceived *prometheus.CounterVec
+}
+
+func NewPoll(r prometheus.Registerer) (*Poll, error) {
+	m := &Poll{
+		CycleDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "poll_duration_seconds",
+				Help:    "A histogram of poll cycle latencies.",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+		Cycles: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "poll_cycles_total",
+				Help: "A counter of poll cycles by outcome: success or failure.",
+			}, []string{"outcome"},
+		),
+		LastPrice: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "quote_last_price",
+				Help: "A gauge of the last price polled per symbol.",
+			}, []string{"symbol"},
+		),
+		QuotesArchived: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "poll_quotes_archived_total",
+				Help: "A counter of polled quotes archived per symbol.",
+			}, []string{"symbol"},
+		),
+		QuotesReceived: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "poll_quotes_received_total",
+				Help: "A counter of quotes received from the provider per symbol.",
+			}, []string{"symbol"},
+		),
+	}
+
+	var err error
+	if m.CycleDuration, err = registerHistogram(r, m.CycleDuration); err != nil {
+		return nil, err
+	}
+	if m.Cycles, err = registerCounterVec(r, m.Cycles); err != nil {
+		return nil, err
+	}
+	if m.LastPrice, err = registerGaugeVec(r, m.LastPrice); err != nil {
+		return nil, err
+	}
+	if m.QuotesArchived, err = registerCounterVec(r, m.QuotesArchived); err != nil {
+		return nil, err
+	}
+	if m.QuotesReceived, err = registerCounterVec(r, m.QuotesReceived); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+var quoteAgeDesc = prometheus.NewDesc(
+	"quote_age_seconds",
+	"A gauge of the age of the newest quote per symbol.",
+	[]string{"symbol"}, nil,
+)
+
+// quoteAge reports the age of the newest quotes at collection time.
+type quoteAge func() map[string]time.Time
+
+func (q quoteAge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- quoteAgeDesc
+}
+
+func (q quoteAge) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for symbol, t := range q() {
+		ch <- prometheus.MustNewConstMetric(quoteAgeDesc,
+			prometheus.GaugeValue, now.Sub(t).Seconds(), symbol)
+	}
+}
+
+// RegisterQuoteAge registers the quote_age_seconds gauge, which newest
+// supplies with the time of each symbol's newest quote when collected. Only
+// the first function registered with r reports.
+func RegisterQuoteAge(r prometheus.Registerer,
+	newest func() map[string]time.Time) error {
+	_, err := register(r, quoteAge(newest))
+
+	return err
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// register registers c with r, if r isn't nil, and returns the collector to
+// use: c, or the identical collector already registered, so components
+// sharing a registry share their metrics instead of colliding.
+func register(r prometheus.Registerer, c prometheus.Collector) (
+	prometheus.Collector, error) {
+	if r == nil {
+		return c, nil
+	}
+
+	err := r.Register(c)
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return are.ExistingCollector, nil
+	}
+
+	return c, err
+}
+
+func registerCounter(r prometheus.Registerer, c prometheus.Counter) (
+	prometheus.Counter, error) {
+	existing, err := register(r, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing.(prometheus.Counter), nil
+}
+
+func registerCounterVec(r prometheus.Registerer, c *prometheus.CounterVec) (
+	*prometheus.CounterVec, error) {
+	existing, err := register(r, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing.(*prometheus.CounterVec), nil
+}
+
+func registerGauge(r prometheus.Registerer, g prometheus.Gauge) (
+	prometheus.Gauge, error) {
+	existing, err := register(r, g)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing.(prometheus.Gauge), nil
+}
+
+func registerGaugeVec(r prometheus.Registerer, g *prometheus.GaugeVec) (
+	*prometheus.GaugeVec, error) {
+	existing, err := register(r, g)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing.(*prometheus.GaugeVec), nil
+}
+
+func registerHistogram(r prometheus.Registerer, h prometheus.Histogram) (
+	prometheus.Histogram, error) {
+	existing, err := register(r, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing.(prometheus.Histogram), nil
+}
+
+func registerHistogramVec(r prometheus.Registerer, h *prometheus.HistogramVec) (
+	*prometheus.HistogramVec, error) {
+	existing, err := register(r, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return existing.(*prometheus.HistogramVec), nil
+}
@@ -1,22 +1,92 @@
 package metrics
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 )
 
-func New(options ...Option) *http.Server {
-	s := &http.Server{
-		Addr:              ":2112",
-		Handler:           promhttp.Handler(),
-		IdleTimeout:       time.Minute,
-		ReadHeaderTimeout: 30 * time.Second,
+const (
+	// DefaultListenAddress only accepts local connections, like pprof's;
+	// metrics reveal the symbols tracked and the traffic served.
+	DefaultListenAddress = "localhost:2112"
+
+	DefaultPath = "/metrics"
+)
+
+// Server exposes the metrics gathered from a registry on its own port.
+type Server struct {
+	ctx               context.Context
+	srv               *http.Server
+	log               *zap.SugaredLogger
+	listenAddr        string
+	path              string
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	username          string
+	password          string
+}
+
+func (s *Server) ListenAndServe() error {
+	go s.shutdown()
+
+	s.log.Infof("Listening on %q", s.srv.Addr)
+	return s.srv.ListenAndServe()
+}
+
+func (s *Server) ListenAndServeTLS(cert, pkey string) error {
+	go s.shutdown()
+
+	s.log.Infof("Listening on %q (TLS)", s.srv.Addr)
+	return s.srv.ListenAndServeTLS(cert, pkey)
+}
+
+// shutdown gracefully shuts down the server once its context is canceled.
+func (s *Server) shutdown() {
+	<-s.ctx.Done()
+	s.log.Info("shutting down ...")
+	sCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = s.srv.Shutdown(sCtx)
+}
+
+// Handler returns a handler exposing the metrics gathered by g. If username
+// isn't empty, requests must present it and password with basic auth.
+func Handler(g prometheus.Gatherer, username, password string) http.Handler {
+	return auth.BasicAuth(promhttp.HandlerFor(g, promhttp.HandlerOpts{}),
+		"metrics", username, password)
+}
+
+// New returns a server exposing the metrics gathered by g at the path.
+func New(ctx context.Context, g prometheus.Gatherer, log *zap.SugaredLogger,
+	options ...Option) *Server {
+	s := &Server{
+		ctx:               ctx,
+		log:               log.Named("metrics"),
+		listenAddr:        DefaultListenAddress,
+		path:              DefaultPath,
+		idleTimeout:       time.Minute,
+		readHeaderTimeout: 30 * time.Second,
 	}
 
 	for _, option := range options {
-		option(s)
+		if option != nil {
+			option(s)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(s.path, Handler(g, s.username, s.password))
+	s.srv = &http.Server{
+		Addr:              s.listenAddr,
+		Handler:           mux,
+		IdleTimeout:       s.idleTimeout,
+		ReadHeaderTimeout: s.readHeaderTimeout,
 	}
 
 	return s
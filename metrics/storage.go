@@ -0,0 +1,61 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Storage instruments the history backends. Metrics are labeled with the
+// backend.
+type Storage struct {
+	ArchiveDuration *prometheus.HistogramVec
+	ArchiveErrors   *prometheus.CounterVec
+}
+
+func NewStorage(r prometheus.Registerer) (*Storage, error) {
+	m := &Storage{
+		ArchiveDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "archive_duration_seconds",
+				Help:    "A histogram of archive latencies per backend.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"backend"},
+		),
+		ArchiveErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "archive_errors_total",
+				Help: "A counter of failed archive calls per backend.",
+			}, []string{"backend"},
+		),
+	}
+
+	var err error
+	if m.ArchiveDuration, err = registerHistogramVec(r, m.ArchiveDuration); err != nil {
+		return nil, err
+	}
+	if m.ArchiveErrors, err = registerCounterVec(r, m.ArchiveErrors); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// RegisterSQLite registers gauges of the SQLite quote count and database
+// size in bytes, which rows and size supply when collected.
+func RegisterSQLite(r prometheus.Registerer, rows, size func() float64) error {
+	_, err := register(r, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "sqlite_quotes_rows",
+			Help: "A gauge of rows in the SQLite quotes table.",
+		}, rows,
+	))
+	if err != nil {
+		return err
+	}
+
+	_, err = register(r, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "sqlite_database_size_bytes",
+			Help: "A gauge of the SQLite database size.",
+		}, size,
+	))
+
+	return err
+}
@@ -0,0 +1,20 @@
+package poll
+
+import "github.com/prometheus/client_golang/prometheus"
+
+type Option func(*Poller)
+
+// ArchiveOnPriceChange directs the poller to archive a symbol's quote only
+// when its price differs from the last quote archived for the symbol.
+func ArchiveOnPriceChange() Option {
+	return func(p *Poller) {
+		p.lastPrices = make(map[string]float64)
+	}
+}
+
+// Registerer registers the poller's metrics with r.
+func Registerer(r prometheus.Registerer) Option {
+	return func(p *Poller) {
+		p.registerer = r
+	}
+}
@@ -3,28 +3,92 @@ package poll
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
 	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 const DefaultPollDuration = time.Minute
 
+var tracer = otel.Tracer("github.com/cry0genic/go-stocks/poll")
+
 var (
 	ErrNilArchiver = fmt.Errorf("archiver cannot be nil")
 	ErrNilLogger   = fmt.Errorf("logger cannot be nil")
 	ErrNilProvider = fmt.Errorf("finance provider cannot be nil")
 )
 
+// Status reports the outcome of a poller's recent polls.
+type Status struct {
+	Interval    time.Duration
+	Started     time.Time
+	LastSuccess time.Time
+	LastError   time.Time
+	Err         error // the last poll's error, if it failed
+}
+
 type Poller struct {
 	log      *zap.SugaredLogger
 	archiver history.Archiver
 	provider finance.Provider
+
+	registerer prometheus.Registerer
+	duplicates prometheus.Counter
+	metrics    *metrics.Poll
+
+	// lastPrices holds the last archived price per symbol when archiving
+	// only on price changes; it's nil otherwise.
+	lastPrices map[string]float64
+
+	mu        sync.RWMutex
+	status    Status
+	symbols   []string
+	intervals chan time.Duration   // signals SetInterval to a running Poll
+	newest    map[string]time.Time // newest archived quote time per symbol
+}
+
+// Status returns the outcome of the poller's recent polls.
+func (p *Poller) Status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.status
+}
+
+// Ready returns an error unless a poll succeeded within the last two
+// intervals, or the poller started polling less than two intervals ago.
+func (p *Poller) Ready() error {
+	s := p.Status()
+	if s.Started.IsZero() {
+		return fmt.Errorf("not polling")
+	}
+
+	since := s.LastSuccess
+	if since.IsZero() {
+		since = s.Started
+	}
+	if age := time.Since(since); age > 2*s.Interval {
+		if s.Err != nil {
+			return fmt.Errorf("no successful poll in %s: %w",
+				age.Truncate(time.Second), s.Err)
+		}
+		return fmt.Errorf("no successful poll in %s", age.Truncate(time.Second))
+	}
+
+	return nil
 }
 
-func (p Poller) Poll(ctx context.Context, interval time.Duration,
+func (p *Poller) Poll(ctx context.Context, interval time.Duration,
 	symbols ...string) {
 	if len(symbols) == 0 {
 		p.log.Warn("no symbols to poll")
@@ -36,34 +100,232 @@ func (p Poller) Poll(ctx context.Context, interval time.Duration,
 	}
 
 	p.log.Infof("polling interval: %s", interval)
+	p.mu.Lock()
+	p.status = Status{Interval: interval, Started: time.Now()}
+	p.symbols = symbols
+	select {
+	case <-p.intervals:
+	default:
+	}
+	p.mu.Unlock()
+
 	t := time.NewTicker(interval)
 	defer t.Stop()
 
 	for {
-		quotes, err := p.provider.GetQuotes(ctx, symbols...)
+		start := time.Now()
+		err := p.poll(ctx, p.Symbols())
+		p.metrics.CycleDuration.Observe(time.Since(start).Seconds())
 		if err != nil {
-			p.log.Errorf("polling provider: %v", err)
-		} else {
-			p.log.Debugf("received: %#v", quotes)
-			err = p.archiver.SetQuotes(ctx, quotes)
-			if err != nil {
-				p.log.Errorf("updating history: %v", err)
-				continue
-			}
-			p.log.Debug("stored")
+			p.log.Error(err)
 		}
+		p.record(err)
 
-		select {
-		case <-ctx.Done():
+		if !p.wait(ctx, t) {
 			p.log.Debug("stopping poller")
 			return
+		}
+	}
+}
+
+// wait blocks until the ticker fires, resetting it whenever the interval
+// changes. It returns false once ctx is canceled.
+func (p *Poller) wait(ctx context.Context, t *time.Ticker) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case d := <-p.intervals:
+			p.log.Infof("polling interval: %s", d)
+			t.Reset(d)
 		case <-t.C:
+			// Both may be ready; stop rather than poll once more.
+			return ctx.Err() == nil
+		}
+	}
+}
+
+// SetInterval changes the poll interval, starting with the next interval.
+func (p *Poller) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if d == p.status.Interval {
+		return
+	}
+	p.status.Interval = d
+
+	// Replace any change Poll hasn't picked up yet.
+	select {
+	case <-p.intervals:
+	default:
+	}
+	p.intervals <- d
+}
+
+// SetSymbols changes the symbols polled, starting with the next poll. It
+// forgets the newest quotes and deletes the metrics of the symbols removed,
+// so their quotes don't look ever staler.
+func (p *Poller) SetSymbols(symbols ...string) {
+	if len(symbols) == 0 {
+		return
+	}
+	s := make([]string, len(symbols))
+	copy(s, symbols)
+
+	p.mu.Lock()
+	old := p.symbols
+	p.symbols = s
+	var removed []string
+	for _, symbol := range old {
+		symbol = strings.ToLower(symbol)
+		if !p.polls(symbol) {
+			removed = append(removed, symbol)
+			delete(p.newest, symbol)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, symbol := range removed {
+		p.metrics.LastPrice.DeleteLabelValues(symbol)
+		p.metrics.QuotesArchived.DeleteLabelValues(symbol)
+		p.metrics.QuotesReceived.DeleteLabelValues(symbol)
+	}
+}
+
+// polls returns true if the lowercase symbol is among those polled. The
+// caller must hold p.mu.
+func (p *Poller) polls(symbol string) bool {
+	for _, s := range p.symbols {
+		if strings.EqualFold(s, symbol) {
+			return true
 		}
 	}
+
+	return false
+}
+
+// Symbols returns the symbols polled.
+func (p *Poller) Symbols() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.symbols
+}
+
+func (p *Poller) poll(ctx context.Context, symbols []string) (err error) {
+	ctx, span := tracer.Start(ctx, "poll", trace.WithAttributes(
+		attribute.Int("poll.symbols", len(symbols))))
+	defer func() { tracing.End(span, err) }()
+
+	quotes, err := p.provider.GetQuotes(ctx, symbols...)
+	if err != nil {
+		return fmt.Errorf("polling provider: %w", err)
+	}
+	p.log.Debugf("received: %#v", quotes)
+	for _, q := range quotes {
+		symbol := strings.ToLower(q.Symbol)
+		p.metrics.QuotesReceived.WithLabelValues(symbol).Inc()
+		p.metrics.LastPrice.WithLabelValues(symbol).Set(q.Price)
+	}
+
+	changed := p.priceChanges(quotes)
+	span.SetAttributes(attribute.Int("poll.archived", len(changed)))
+	err = p.archiver.SetQuotes(ctx, changed)
+	if err != nil {
+		return fmt.Errorf("updating history: %w", err)
+	}
+	for _, q := range changed {
+		p.metrics.QuotesArchived.WithLabelValues(strings.ToLower(q.Symbol)).Inc()
+	}
+	p.recordPrices(changed)
+	p.recordNewest(quotes)
+	p.log.Debug("stored")
+
+	return nil
+}
+
+func (p *Poller) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.Err = err
+	if err != nil {
+		p.status.LastError = time.Now()
+		p.metrics.Cycles.WithLabelValues("failure").Inc()
+		return
+	}
+	p.status.LastSuccess = time.Now()
+	p.metrics.Cycles.WithLabelValues("success").Inc()
+}
+
+// recordNewest notes the time of each symbol's newest quote. Quotes skipped
+// for an unchanged price count, since they confirm the archived price.
+// Symbols removed while the quotes were polled are skipped.
+func (p *Poller) recordNewest(quotes []finance.Quote) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, q := range quotes {
+		symbol := strings.ToLower(q.Symbol)
+		if !p.polls(symbol) {
+			continue
+		}
+		if q.Time.After(p.newest[symbol]) {
+			p.newest[symbol] = q.Time
+		}
+	}
+}
+
+// newestQuotes returns a copy of the newest quote time per symbol.
+func (p *Poller) newestQuotes() map[string]time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	newest := make(map[string]time.Time, len(p.newest))
+	for symbol, t := range p.newest {
+		newest[symbol] = t
+	}
+
+	return newest
+}
+
+// priceChanges returns the quotes whose price differs from the last archived
+// price of their symbol, or all quotes unless archiving on price changes.
+func (p *Poller) priceChanges(quotes []finance.Quote) []finance.Quote {
+	if p.lastPrices == nil {
+		return quotes
+	}
+
+	changed := make([]finance.Quote, 0, len(quotes))
+	for _, q := range quotes {
+		last, ok := p.lastPrices[strings.ToLower(q.Symbol)]
+		if ok && last == q.Price {
+			continue
+		}
+		changed = append(changed, q)
+	}
+	p.duplicates.Add(float64(len(quotes) - len(changed)))
+
+	return changed
+}
+
+func (p *Poller) recordPrices(quotes []finance.Quote) {
+	if p.lastPrices == nil {
+		return
+	}
+
+	for _, q := range quotes {
+		p.lastPrices[strings.ToLower(q.Symbol)] = q.Price
+	}
 }
 
-func New(p finance.Provider, a history.Archiver, l *zap.SugaredLogger) (
-	*Poller, error) {
+func New(p finance.Provider, a history.Archiver, l *zap.SugaredLogger,
+	options ...Option) (*Poller, error) {
 	switch {
 	case p == nil:
 		return nil, ErrNilProvider
@@ -73,9 +335,34 @@ func New(p finance.Provider, a history.Archiver, l *zap.SugaredLogger) (
 		return nil, ErrNilLogger
 	}
 
-	return &Poller{
-		log:      l.Named("poll"),
-		archiver: a,
-		provider: p,
-	}, nil
+	poller := &Poller{
+		log:       l.Named("poll"),
+		archiver:  a,
+		provider:  p,
+		intervals: make(chan time.Duration, 1),
+		newest:    make(map[string]time.Time),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(poller)
+		}
+	}
+
+	duplicates, err := metrics.NewDuplicateQuotes(poller.registerer)
+	if err != nil {
+		return nil, err
+	}
+	poller.duplicates = duplicates.WithLabelValues("poll")
+
+	poller.metrics, err = metrics.NewPoll(poller.registerer)
+	if err != nil {
+		return nil, err
+	}
+	err = metrics.RegisterQuoteAge(poller.registerer, poller.newestQuotes)
+	if err != nil {
+		return nil, err
+	}
+
+	return poller, nil
 }
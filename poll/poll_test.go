@@ -2,12 +2,15 @@ package poll
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/cry0genic/go-stocks/finance"
 	"github.com/cry0genic/go-stocks/history"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.uber.org/zap/zaptest"
 )
 
@@ -71,6 +74,214 @@ func TestPoller(t *testing.T) {
 	}
 }
 
+func TestPollerArchiveOnPriceChange(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	m := &mockProviderArchiver{
+		cancel: cancel,
+		quotes: []finance.Quote{
+			{Price: 123.45, Symbol: "fb", Time: now},
+			{Price: 123.45, Symbol: "fb", Time: now.Add(time.Minute)},
+			{Price: 123.42, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+		},
+		storage: make([]finance.Quote, 0, 2),
+	}
+
+	p, err := New(m, m, zaptest.NewLogger(t).Sugar(), ArchiveOnPriceChange())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Poll(ctx, 10*time.Millisecond, "fb")
+		close(done)
+	}()
+	<-done
+
+	expected := []finance.Quote{
+		{Price: 123.42, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+		{Price: 123.45, Symbol: "fb", Time: now},
+	}
+	if !reflect.DeepEqual(m.storage, expected) {
+		t.Error("storage does not equal expected")
+		t.Logf("storage:  %#v", m.storage)
+		t.Logf("expected: %#v", expected)
+	}
+}
+
+func TestPollerStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := &mockProviderArchiver{
+		cancel: cancel,
+		quotes: []finance.Quote{{Price: 123.45, Symbol: "fb", Time: time.Now()}},
+	}
+	p, err := New(m, failingArchiver{}, zaptest.NewLogger(t).Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Ready(); err == nil {
+		t.Error("expected a poller that hasn't started to not be ready")
+	}
+
+	p.Poll(ctx, time.Nanosecond, "fb")
+
+	s := p.Status()
+	if s.Err == nil || s.LastError.IsZero() || !s.LastSuccess.IsZero() {
+		t.Errorf("unexpected status after failed poll: %#v", s)
+	}
+	if err = p.Ready(); !errors.Is(err, errArchive) {
+		t.Errorf("actual: %v; expected: %v", err, errArchive)
+	}
+}
+
+func TestPollerMetrics(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now().Add(-time.Hour)
+	m := &mockProviderArchiver{
+		cancel: cancel,
+		quotes: []finance.Quote{
+			{Price: 123.45, Symbol: "fb", Time: now},
+			{Price: 123.45, Symbol: "fb", Time: now.Add(time.Minute)},
+			{Price: 123.42, Symbol: "fb", Time: now.Add(2 * time.Minute)},
+		},
+	}
+
+	reg := prometheus.NewRegistry()
+	p, err := New(m, m, zaptest.NewLogger(t).Sugar(), ArchiveOnPriceChange(),
+		Registerer(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Poll(ctx, time.Nanosecond, "fb")
+
+	testCases := []struct {
+		collector prometheus.Collector
+		expected  float64
+	}{
+		{collector: p.metrics.Cycles.WithLabelValues("success"), expected: 3},
+		{collector: p.metrics.QuotesReceived.WithLabelValues("fb"), expected: 3},
+		{collector: p.metrics.QuotesArchived.WithLabelValues("fb"), expected: 2},
+		{collector: p.metrics.LastPrice.WithLabelValues("fb"), expected: 123.42},
+		{collector: p.duplicates, expected: 1},
+	}
+	for i, tc := range testCases {
+		if actual := testutil.ToFloat64(tc.collector); actual != tc.expected {
+			t.Errorf("%d: actual: %v; expected: %v", i, actual, tc.expected)
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var age float64
+	for _, f := range families {
+		if f.GetName() == "quote_age_seconds" {
+			age = f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	if age < (58*time.Minute).Seconds() || age > time.Hour.Seconds() {
+		t.Errorf("actual quote age: %vs; expected about 58m", age)
+	}
+
+	// Removing a symbol deletes its series.
+	p.SetSymbols("aapl")
+	families, err = reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		switch f.GetName() {
+		case "quote_age_seconds", "quote_last_price",
+			"poll_quotes_archived_total", "poll_quotes_received_total":
+			t.Errorf("%s has %d series after removing fb", f.GetName(),
+				len(f.GetMetric()))
+		}
+	}
+}
+
+func TestPollerSetIntervalAndSymbols(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var p *Poller
+	m := &recordingProvider{}
+	m.getQuotes = func(symbols []string) {
+		m.symbols = append(m.symbols, symbols)
+		if len(m.symbols) == 1 {
+			// Without the new interval, the next poll is an hour away.
+			p.SetSymbols("aapl", "nflx")
+			p.SetInterval(time.Millisecond)
+			return
+		}
+		cancel()
+	}
+
+	var err error
+	p, err = New(m, &mockProviderArchiver{}, zaptest.NewLogger(t).Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Poll(ctx, time.Hour, "fb")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poller didn't use the new interval")
+	}
+
+	expected := [][]string{{"fb"}, {"aapl", "nflx"}}
+	if !reflect.DeepEqual(m.symbols, expected) {
+		t.Errorf("actual: %v; expected: %v", m.symbols, expected)
+	}
+	if actual := p.Status().Interval; actual != time.Millisecond {
+		t.Errorf("actual interval: %s; expected: %s", actual, time.Millisecond)
+	}
+}
+
+var errArchive = errors.New("archive failed")
+
+type recordingProvider struct {
+	getQuotes func(symbols []string)
+	symbols   [][]string
+}
+
+func (r *recordingProvider) GetQuotes(_ context.Context, symbols ...string) (
+	[]finance.Quote, error) {
+	r.getQuotes(symbols)
+
+	return nil, nil
+}
+
+type failingArchiver struct{}
+
+func (failingArchiver) Close() error { return nil }
+
+func (failingArchiver) SetQuotes(context.Context, []finance.Quote) error {
+	return errArchive
+}
+
 var (
 	_ finance.Provider = (*mockProviderArchiver)(nil)
 	_ history.Archiver = (*mockProviderArchiver)(nil)
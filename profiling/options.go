@@ -0,0 +1,34 @@
+package profiling
+
+import "time"
+
+type Option func(*Server)
+
+// BasicAuth requires requests to present the username and password with
+// basic auth.
+func BasicAuth(username, password string) Option {
+	return func(s *Server) {
+		s.username = username
+		s.password = password
+	}
+}
+
+func IdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+func ListenAddress(addr string) Option {
+	return func(s *Server) {
+		if addr != "" {
+			s.listenAddr = addr
+		}
+	}
+}
+
+func ReadHeaderTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.readHeaderTimeout = d
+	}
+}
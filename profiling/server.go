@@ -0,0 +1,87 @@
+// Package profiling serves the net/http/pprof profiles on a port of their
+// own, apart from the API.
+package profiling
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"go.uber.org/zap"
+)
+
+// DefaultListenAddress only accepts local connections; profiles expose the
+// process's internals and profiling it costs CPU.
+const DefaultListenAddress = "localhost:6060"
+
+// Server serves the pprof profiles under /debug/pprof/.
+type Server struct {
+	ctx               context.Context
+	srv               *http.Server
+	log               *zap.SugaredLogger
+	listenAddr        string
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	username          string
+	password          string
+}
+
+func (s *Server) ListenAndServe() error {
+	go s.shutdown()
+
+	s.log.Infof("Listening on %q", s.srv.Addr)
+	return s.srv.ListenAndServe()
+}
+
+// shutdown gracefully shuts down the server once its context is canceled.
+func (s *Server) shutdown() {
+	<-s.ctx.Done()
+	s.log.Info("shutting down ...")
+	sCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = s.srv.Shutdown(sCtx)
+}
+
+// Handler returns a handler serving the pprof profiles under /debug/pprof/.
+// If username isn't empty, requests must present it and password with basic
+// auth.
+func Handler(username, password string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return auth.BasicAuth(mux, "pprof", username, password)
+}
+
+func New(ctx context.Context, log *zap.SugaredLogger,
+	options ...Option) *Server {
+	s := &Server{
+		ctx:               ctx,
+		log:               log.Named("pprof"),
+		listenAddr:        DefaultListenAddress,
+		idleTimeout:       time.Minute,
+		readHeaderTimeout: 30 * time.Second,
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(s)
+		}
+	}
+
+	// No WriteTimeout: CPU profiles and traces take as long as the client
+	// asks.
+	s.srv = &http.Server{
+		Addr:              s.listenAddr,
+		Handler:           Handler(s.username, s.password),
+		IdleTimeout:       s.idleTimeout,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+	}
+
+	return s
+}
@@ -0,0 +1,54 @@
+package profiling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		username, password string // the handler's credentials
+		user, pass         string // the request's, if user isn't empty
+		path               string
+		expected           int
+	}{
+		{path: "/debug/pprof/", expected: http.StatusOK},
+		{path: "/debug/pprof/cmdline", expected: http.StatusOK},
+		{path: "/debug/pprof/goroutine?debug=1", expected: http.StatusOK},
+		{path: "/", expected: http.StatusNotFound},
+		{
+			username: "ops", password: "secret",
+			path: "/debug/pprof/", expected: http.StatusUnauthorized,
+		},
+		{
+			username: "ops", password: "secret", user: "ops", pass: "wrong",
+			path: "/debug/pprof/", expected: http.StatusUnauthorized,
+		},
+		{
+			username: "ops", password: "secret", user: "ops", pass: "secret",
+			path: "/debug/pprof/", expected: http.StatusOK,
+		},
+	}
+
+	for i, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.user != "" {
+			r.SetBasicAuth(tc.user, tc.pass)
+		}
+		w := httptest.NewRecorder()
+		Handler(tc.username, tc.password).ServeHTTP(w, r)
+
+		if w.Code != tc.expected {
+			t.Errorf("%d: actual status: %d; expected: %d", i, w.Code,
+				tc.expected)
+		}
+		if tc.expected == http.StatusUnauthorized &&
+			w.Header().Get("WWW-Authenticate") != `Basic realm="pprof"` {
+			t.Errorf("%d: actual WWW-Authenticate: %q", i,
+				w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/tlsconfig"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// apiKey returns the key in the x-api-key metadata or the bearer token in the
+// authorization metadata of the incoming RPC.
+func apiKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if k := md.Get("x-api-key"); len(k) > 0 && k[0] != "" {
+		return k[0]
+	}
+
+	const bearer = "bearer "
+	for _, h := range md.Get("authorization") {
+		if len(h) > len(bearer) && strings.EqualFold(h[:len(bearer)], bearer) {
+			return strings.TrimSpace(h[len(bearer):])
+		}
+	}
+
+	return ""
+}
+
+// authenticate returns an error unless the RPC carries an active API key
+// granting scope and within the key's rate limit. A nil limiter doesn't
+// limit.
+func authenticate(ctx context.Context, keys auth.Store,
+	limiter *auth.RateLimiter, scope auth.Scope, log *zap.SugaredLogger) error {
+	token := apiKey(ctx)
+	if token == "" {
+		return status.Error(codes.Unauthenticated, "missing API key")
+	}
+	if auth.Validate(token) != nil {
+		return status.Error(codes.Unauthenticated, "invalid API key")
+	}
+
+	k, err := keys.APIKeyByHash(ctx, auth.Hash(token))
+	switch {
+	case errors.Is(err, auth.ErrUnknownKey):
+		return status.Error(codes.Unauthenticated, "invalid API key")
+	case err != nil:
+		log.Error(err)
+		return status.Error(codes.Internal, "internal error")
+	case !k.Active():
+		return status.Error(codes.Unauthenticated, "revoked API key")
+	case !k.Allows(scope):
+		return status.Error(codes.PermissionDenied,
+			fmt.Sprintf("API key lacks the %q scope", scope))
+	}
+
+	if limiter != nil {
+		if wait, ok := limiter.Allow(k.ID); !ok {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after",
+				strconv.Itoa(int(math.Ceil(wait.Seconds())))))
+			return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+	}
+
+	return nil
+}
+
+func authUnaryInterceptor(keys auth.Store, limiter *auth.RateLimiter,
+	log *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		_ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authenticate(ctx, keys, limiter, auth.ScopeRead, log); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func authStreamInterceptor(keys auth.Store, limiter *auth.RateLimiter,
+	log *zap.SugaredLogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		_ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := authenticate(ss.Context(), keys, limiter, auth.ScopeRead, log)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// verified returns an error unless the RPC's client presented a verified
+// certificate.
+func verified(ctx context.Context) error {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok &&
+			tlsconfig.Verified(&info.State) {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "client certificate required")
+}
+
+func clientCertUnaryInterceptor(ctx context.Context, req interface{},
+	_ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := verified(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func clientCertStreamInterceptor(srv interface{}, ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := verified(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+func metricsUnaryInterceptor(m *metrics.GRPCServer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(m, "unary", info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+func metricsStreamInterceptor(m *metrics.GRPCServer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		typ := "server_stream"
+		if info.IsClientStream {
+			typ = "bidi_stream"
+		}
+
+		start := time.Now()
+		err := handler(srv, &countingStream{
+			ServerStream: ss,
+			sent:         m.MsgSent.WithLabelValues(typ, info.FullMethod),
+		})
+		observe(m, typ, info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func observe(m *metrics.GRPCServer, typ, method string, start time.Time,
+	err error) {
+	m.HandlingSeconds.WithLabelValues(typ, method).
+		Observe(time.Since(start).Seconds())
+	m.Handled.WithLabelValues(typ, method, status.Code(err).String()).Inc()
+}
+
+// countingStream counts the messages sent on a server stream.
+type countingStream struct {
+	grpc.ServerStream
+	sent interface{ Inc() }
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Inc()
+	}
+
+	return err
+}
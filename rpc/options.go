@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Option func(*Server)
+
+// Authenticate requires an active API key from keys, in the x-api-key
+// metadata or as a bearer token, on every RPC.
+func Authenticate(keys auth.Store) Option {
+	return func(s *Server) {
+		s.keys = keys
+	}
+}
+
+func DisableInstrumentation() Option {
+	return func(s *Server) {
+		s.instrumentation = false
+	}
+}
+
+func ListenAddress(addr string) Option {
+	return func(s *Server) {
+		if addr != "" {
+			s.listenAddr = addr
+		}
+	}
+}
+
+// MaxLast sets the largest "last" field the service accepts.
+func MaxLast(i int) Option {
+	return func(s *Server) {
+		if i > 0 {
+			s.maxLast = i
+		}
+	}
+}
+
+// RateLimiter limits each API key's RPCs with l, which the REST API may
+// share. It applies only to authenticated RPCs.
+func RateLimiter(l *auth.RateLimiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+// Registerer registers the server's metrics with r, unless instrumentation
+// is disabled.
+func Registerer(r prometheus.Registerer) Option {
+	return func(s *Server) {
+		s.registerer = r
+	}
+}
+
+// RequireClientCert refuses RPCs from clients without a verified
+// certificate.
+func RequireClientCert() Option {
+	return func(s *Server) {
+		s.requireCert = true
+	}
+}
+
+// StreamInterval sets how often StreamQuotes checks for newer quotes.
+func StreamInterval(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.streamInterval = d
+		}
+	}
+}
+
+// Symbols sets the tracked symbols returned by default and by ListSymbols.
+func Symbols(symbols []string) Option {
+	w := finance.NewWatchlist(symbols)
+
+	return func(s *Server) {
+		if w.Len() > 0 {
+			s.symbols = w
+		}
+	}
+}
+
+// TLSConfig serves the service over TLS with c, such as a
+// tlsconfig.Reloader's configuration.
+func TLSConfig(c *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = c
+	}
+}
+
+// Watchlist shares the tracked symbols in w, so changes to them take effect
+// on the next RPC.
+func Watchlist(w *finance.Watchlist) Option {
+	return func(s *Server) {
+		if w != nil {
+			s.symbols = w
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package pb holds the generated protocol buffer and gRPC code for the
+// Stonks service.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative stonks.proto
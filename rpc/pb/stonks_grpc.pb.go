@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.19.1
+// source: stonks.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// StonksClient is the client API for Stonks service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StonksClient interface {
+	// GetQuote returns a symbol's quotes, newest first.
+	GetQuote(ctx context.Context, in *GetQuoteRequest, opts ...grpc.CallOption) (*GetQuoteResponse, error)
+	// GetQuotesBatch returns the quotes for several symbols, newest first.
+	GetQuotesBatch(ctx context.Context, in *GetQuotesBatchRequest, opts ...grpc.CallOption) (*GetQuotesBatchResponse, error)
+	// StreamQuotes sends each symbol's latest quote, and then each newer quote
+	// that becomes the latest.
+	StreamQuotes(ctx context.Context, in *StreamQuotesRequest, opts ...grpc.CallOption) (Stonks_StreamQuotesClient, error)
+	// ListSymbols returns the tracked symbols.
+	ListSymbols(ctx context.Context, in *ListSymbolsRequest, opts ...grpc.CallOption) (*ListSymbolsResponse, error)
+}
+
+type stonksClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStonksClient(cc grpc.ClientConnInterface) StonksClient {
+	return &stonksClient{cc}
+}
+
+func (c *stonksClient) GetQuote(ctx context.Context, in *GetQuoteRequest, opts ...grpc.CallOption) (*GetQuoteResponse, error) {
+	out := new(GetQuoteResponse)
+	err := c.cc.Invoke(ctx, "/stonks.v1.Stonks/GetQuote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stonksClient) GetQuotesBatch(ctx context.Context, in *GetQuotesBatchRequest, opts ...grpc.CallOption) (*GetQuotesBatchResponse, error) {
+	out := new(GetQuotesBatchResponse)
+	err := c.cc.Invoke(ctx, "/stonks.v1.Stonks/GetQuotesBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stonksClient) StreamQuotes(ctx context.Context, in *StreamQuotesRequest, opts ...grpc.CallOption) (Stonks_StreamQuotesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Stonks_ServiceDesc.Streams[0], "/stonks.v1.Stonks/StreamQuotes", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &stonksStreamQuotesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Stonks_StreamQuotesClient interface {
+	Recv() (*Quote, error)
+	grpc.ClientStream
+}
+
+type stonksStreamQuotesClient struct {
+	grpc.ClientStream
+}
+
+func (x *stonksStreamQuotesClient) Recv() (*Quote, error) {
+	m := new(Quote)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *stonksClient) ListSymbols(ctx context.Context, in *ListSymbolsRequest, opts ...grpc.CallOption) (*ListSymbolsResponse, error) {
+	out := new(ListSymbolsResponse)
+	err := c.cc.Invoke(ctx, "/stonks.v1.Stonks/ListSymbols", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StonksServer is the server API for Stonks service.
+// All implementations must embed UnimplementedStonksServer
+// for forward compatibility
+type StonksServer interface {
+	// GetQuote returns a symbol's quotes, newest first.
+	GetQuote(context.Context, *GetQuoteRequest) (*GetQuoteResponse, error)
+	// GetQuotesBatch returns the quotes for several symbols, newest first.
+	GetQuotesBatch(context.Context, *GetQuotesBatchRequest) (*GetQuotesBatchResponse, error)
+	// StreamQuotes sends each symbol's latest quote, and then each newer quote
+	// that becomes the latest.
+	StreamQuotes(*StreamQuotesRequest, Stonks_StreamQuotesServer) error
+	// ListSymbols returns the tracked symbols.
+	ListSymbols(context.Context, *ListSymbolsRequest) (*ListSymbolsResponse, error)
+	mustEmbedUnimplementedStonksServer()
+}
+
+// UnimplementedStonksServer must be embedded to have forward compatible implementations.
+type UnimplementedStonksServer struct {
+}
+
+func (UnimplementedStonksServer) GetQuote(context.Context, *GetQuoteRequest) (*GetQuoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuote not implemented")
+}
+func (UnimplementedStonksServer) GetQuotesBatch(context.Context, *GetQuotesBatchRequest) (*GetQuotesBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuotesBatch not implemented")
+}
+func (UnimplementedStonksServer) StreamQuotes(*StreamQuotesRequest, Stonks_StreamQuotesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamQuotes not implemented")
+}
+func (UnimplementedStonksServer) ListSymbols(context.Context, *ListSymbolsRequest) (*ListSymbolsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSymbols not implemented")
+}
+func (UnimplementedStonksServer) mustEmbedUnimplementedStonksServer() {}
+
+// UnsafeStonksServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StonksServer will
+// result in compilation errors.
+type UnsafeStonksServer interface {
+	mustEmbedUnimplementedStonksServer()
+}
+
+func RegisterStonksServer(s grpc.ServiceRegistrar, srv StonksServer) {
+	s.RegisterService(&Stonks_ServiceDesc, srv)
+}
+
+func _Stonks_GetQuote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StonksServer).GetQuote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stonks.v1.Stonks/GetQuote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StonksServer).GetQuote(ctx, req.(*GetQuoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Stonks_GetQuotesBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotesBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StonksServer).GetQuotesBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stonks.v1.Stonks/GetQuotesBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StonksServer).GetQuotesBatch(ctx, req.(*GetQuotesBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Stonks_StreamQuotes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamQuotesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StonksServer).StreamQuotes(m, &stonksStreamQuotesServer{stream})
+}
+
+type Stonks_StreamQuotesServer interface {
+	Send(*Quote) error
+	grpc.ServerStream
+}
+
+type stonksStreamQuotesServer struct {
+	grpc.ServerStream
+}
+
+func (x *stonksStreamQuotesServer) Send(m *Quote) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Stonks_ListSymbols_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSymbolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StonksServer).ListSymbols(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stonks.v1.Stonks/ListSymbols",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StonksServer).ListSymbols(ctx, req.(*ListSymbolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Stonks_ServiceDesc is the grpc.ServiceDesc for Stonks service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Stonks_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "stonks.v1.Stonks",
+	HandlerType: (*StonksServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetQuote",
+			Handler:    _Stonks_GetQuote_Handler,
+		},
+		{
+			MethodName: "GetQuotesBatch",
+			Handler:    _Stonks_GetQuotesBatch_Handler,
+		},
+		{
+			MethodName: "ListSymbols",
+			Handler:    _Stonks_ListSymbols_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamQuotes",
+			Handler:       _Stonks_StreamQuotes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "stonks.proto",
+}
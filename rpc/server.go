@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/metrics"
+	"github.com/cry0genic/go-stocks/rpc/pb"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	DefaultListenAddress = ":18082"
+
+	DefaultMaxLast = 10000
+
+	DefaultStreamInterval = time.Second
+)
+
+type Server struct {
+	ctx             context.Context
+	srv             *grpc.Server
+	log             *zap.SugaredLogger
+	listenAddr      string
+	instrumentation bool
+	keys            auth.Store
+	limiter         *auth.RateLimiter
+	maxLast         int
+	registerer      prometheus.Registerer
+	requireCert     bool
+	streamInterval  time.Duration
+	symbols         *finance.Watchlist
+	tlsConfig       *tls.Config
+}
+
+// ListenAndServe serves the gRPC service on the listen address until the
+// server's context is canceled.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(lis)
+}
+
+// Serve serves the gRPC service on lis until the server's context is
+// canceled. In-flight RPCs get 10 seconds to finish.
+func (s *Server) Serve(lis net.Listener) error {
+	go func() {
+		<-s.ctx.Done()
+		s.log.Info("shutting down ...")
+
+		stopped := make(chan struct{})
+		go func() {
+			s.srv.GracefulStop()
+			close(stopped)
+		}()
+
+		t := time.NewTimer(10 * time.Second)
+		defer t.Stop()
+		select {
+		case <-stopped:
+		case <-t.C:
+			s.srv.Stop()
+		}
+	}()
+
+	s.log.Infof("Listening on %q", lis.Addr())
+	err := s.srv.Serve(lis)
+	if err == grpc.ErrServerStopped {
+		// The context was canceled before the server started.
+		err = nil
+	}
+
+	return err
+}
+
+func New(ctx context.Context, p history.Provider, log *zap.SugaredLogger,
+	options ...Option) (*Server, error) {
+	s := &Server{
+		ctx:             ctx,
+		log:             log.Named("rpc"),
+		listenAddr:      DefaultListenAddress,
+		instrumentation: true,
+		maxLast:         DefaultMaxLast,
+		streamInterval:  DefaultStreamInterval,
+		symbols:         finance.NewWatchlist(finance.DefaultSymbols),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(s)
+		}
+	}
+
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	if s.instrumentation {
+		m, err := metrics.NewGRPCServer(s.registerer)
+		if err != nil {
+			return nil, err
+		}
+		unary = append(unary, metricsUnaryInterceptor(m))
+		stream = append(stream, metricsStreamInterceptor(m))
+		s.log.Info("gRPC instrumented")
+	}
+	if s.requireCert {
+		unary = append(unary, clientCertUnaryInterceptor)
+		stream = append(stream, clientCertStreamInterceptor)
+	}
+	if s.keys != nil {
+		unary = append(unary, authUnaryInterceptor(s.keys, s.limiter, s.log))
+		stream = append(stream, authStreamInterceptor(s.keys, s.limiter, s.log))
+		s.log.Info("gRPC authentication enabled")
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+
+	s.srv = grpc.NewServer(opts...)
+	pb.RegisterStonksServer(s.srv, &service{
+		provider:       p,
+		log:            s.log,
+		maxLast:        s.maxLast,
+		streamInterval: s.streamInterval,
+		symbols:        s.symbols,
+	})
+
+	return s, nil
+}
@@ -0,0 +1,495 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/auth"
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history/memory"
+	"github.com/cry0genic/go-stocks/rpc/pb"
+	"github.com/cry0genic/go-stocks/tlsconfig"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var now = time.Date(2021, 5, 7, 19, 31, 5, 0, time.UTC)
+
+// newClient serves the service backed by provider and returns a client for
+// it. Canceling ctx stops the server.
+func newClient(ctx context.Context, t *testing.T, provider *memory.Client,
+	options ...Option) pb.StonksClient {
+	t.Helper()
+
+	options = append(options, DisableInstrumentation())
+	srv, err := New(ctx, provider, zap.NewNop().Sugar(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.DialContext(ctx, "bufconn",
+		grpc.WithContextDialer(
+			func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			},
+		),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewStonksClient(conn)
+}
+
+func newProvider(t *testing.T) *memory.Client {
+	t.Helper()
+
+	m := memory.New()
+	err := m.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 123.45, Symbol: "fb", Time: now},
+		{Price: 123.42, Symbol: "fb", Time: now.Add(time.Minute)},
+		{Price: 234.56, Symbol: "goog", Time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestGetQuote(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClient(ctx, t, newProvider(t), MaxLast(10))
+
+	resp, err := c.GetQuote(ctx, &pb.GetQuoteRequest{Symbol: "FB", Last: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Quotes) != 1 {
+		t.Fatalf("actual quote count: %d; expected: 1", len(resp.Quotes))
+	}
+	q := resp.Quotes[0]
+	if q.Symbol != "fb" || q.Price != 123.42 ||
+		!q.Time.AsTime().Equal(now.Add(time.Minute)) {
+		t.Errorf("actual quote: %v", q)
+	}
+
+	testCases := []struct {
+		req  *pb.GetQuoteRequest
+		code codes.Code
+	}{
+		{req: &pb.GetQuoteRequest{Symbol: "blah"}, code: codes.NotFound},
+		{req: &pb.GetQuoteRequest{Symbol: ""}, code: codes.InvalidArgument},
+		{req: &pb.GetQuoteRequest{Symbol: "f/b"}, code: codes.InvalidArgument},
+		{
+			req:  &pb.GetQuoteRequest{Symbol: "fb", Last: -1},
+			code: codes.InvalidArgument,
+		},
+		{
+			req:  &pb.GetQuoteRequest{Symbol: "fb", Last: 11},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for i, tc := range testCases {
+		_, err := c.GetQuote(ctx, tc.req)
+		if code := status.Code(err); code != tc.code {
+			t.Errorf("%d: actual code: %s; expected: %s", i, code, tc.code)
+		}
+	}
+}
+
+func TestGetQuotesBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClient(ctx, t, newProvider(t), Symbols([]string{"fb", "GOOG"}))
+
+	testCases := []struct {
+		symbols  []string
+		last     int32
+		counts   map[string]int
+		notFound []string
+	}{
+		{ // tracked symbols
+			counts: map[string]int{"fb": 1, "goog": 1},
+		},
+		{
+			last:   2,
+			counts: map[string]int{"fb": 2, "goog": 1},
+		},
+		{
+			symbols:  []string{"goog", "msft", "GOOG"},
+			counts:   map[string]int{"goog": 1},
+			notFound: []string{"msft"},
+		},
+	}
+
+	for i, tc := range testCases {
+		resp, err := c.GetQuotesBatch(ctx,
+			&pb.GetQuotesBatchRequest{Symbols: tc.symbols, Last: tc.last})
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+
+		counts := make(map[string]int)
+		for symbol, quotes := range resp.Quotes {
+			counts[symbol] = len(quotes.Quotes)
+		}
+		if !reflect.DeepEqual(counts, tc.counts) {
+			t.Errorf("%d: actual counts: %v; expected: %v", i, counts,
+				tc.counts)
+		}
+		if !reflect.DeepEqual(resp.NotFound, tc.notFound) {
+			t.Errorf("%d: actual not found: %q; expected: %q", i,
+				resp.NotFound, tc.notFound)
+		}
+	}
+
+	symbols, err := c.ListSymbols(ctx, &pb.ListSymbolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"fb", "goog"}; !reflect.DeepEqual(
+		symbols.Symbols, expected) {
+		t.Errorf("actual symbols: %q; expected: %q", symbols.Symbols,
+			expected)
+	}
+
+	many := make([]string, maxSymbols+1)
+	for i := range many {
+		many[i] = "fb"
+	}
+	_, err = c.GetQuotesBatch(ctx, &pb.GetQuotesBatchRequest{Symbols: many})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("too many symbols: actual code: %s; expected: %s", code,
+			codes.InvalidArgument)
+	}
+}
+
+func TestStreamQuotes(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider := newProvider(t)
+	c := newClient(ctx, t, provider, StreamInterval(10*time.Millisecond))
+
+	stream, err := c.StreamQuotes(ctx,
+		&pb.StreamQuotesRequest{Symbols: []string{"fb", "nflx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Symbol != "fb" || q.Price != 123.42 {
+		t.Errorf("actual initial quote: %v", q)
+	}
+
+	err = provider.SetQuotes(context.Background(), []finance.Quote{
+		{Price: 504.08, Symbol: "nflx", Time: now.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Symbol != "nflx" || q.Price != 504.08 {
+		t.Errorf("actual streamed quote: %v", q)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv, err := New(ctx, newProvider(t), zap.NewNop().Sugar(),
+		ListenAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe() }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected nil error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("server did not shut down")
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+
+	keys := keyStore{}
+	read := keys.add(t, auth.ScopeRead)
+	none := keys.add(t)
+	revoked := keys.add(t, auth.ScopeRead)
+	k := keys[string(auth.Hash(revoked))]
+	k.Revoked = now
+	keys[string(auth.Hash(revoked))] = k
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClient(ctx, t, newProvider(t), Authenticate(keys),
+		RateLimiter(auth.NewRateLimiter(0.5, 2)))
+
+	testCases := []struct {
+		md   metadata.MD
+		code codes.Code
+	}{
+		{code: codes.Unauthenticated},
+		{md: metadata.Pairs("x-api-key", "nope"), code: codes.Unauthenticated},
+		{md: metadata.Pairs("x-api-key", revoked), code: codes.Unauthenticated},
+		{md: metadata.Pairs("x-api-key", none), code: codes.PermissionDenied},
+		{md: metadata.Pairs("x-api-key", read), code: codes.OK},
+		{md: metadata.Pairs("authorization", "Bearer "+read), code: codes.OK},
+		{md: metadata.Pairs("x-api-key", read), code: codes.ResourceExhausted},
+	}
+
+	for i, tc := range testCases {
+		_, err := c.ListSymbols(metadata.NewOutgoingContext(ctx, tc.md),
+			&pb.ListSymbolsRequest{})
+		if actual := status.Code(err); actual != tc.code {
+			t.Errorf("%d: actual: %s; expected: %s", i, actual, tc.code)
+		}
+	}
+
+	// Streams are checked when they start.
+	stream, err := c.StreamQuotes(ctx, &pb.StreamQuotesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("stream: actual: %v; expected: %s", err, codes.Unauthenticated)
+	}
+}
+
+// keyStore is an auth.Store of keys by their hashes.
+type keyStore map[string]auth.Key
+
+// add creates a key with scopes and returns its token.
+func (s keyStore) add(t *testing.T, scopes ...auth.Scope) string {
+	t.Helper()
+
+	token, k, err := auth.Generate(t.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.Scopes = scopes
+	s[string(auth.Hash(token))] = k
+
+	return token
+}
+
+func (s keyStore) APIKeyByHash(_ context.Context, hash []byte) (auth.Key, error) {
+	k, ok := s[string(hash)]
+	if !ok {
+		return auth.Key{}, auth.ErrUnknownKey
+	}
+
+	return k, nil
+}
+
+func (s keyStore) APIKeys(context.Context) ([]auth.Key, error) {
+	keys := make([]auth.Key, 0, len(s))
+	for _, k := range s {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (s keyStore) CreateAPIKey(_ context.Context, k auth.Key, hash []byte) error {
+	s[string(hash)] = k
+
+	return nil
+}
+
+func (s keyStore) RevokeAPIKey(context.Context, string) error {
+	return auth.ErrUnknownKey
+}
+
+func TestTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newCA(t)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca.issue(t, certFile, keyFile)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certs, err := tlsconfig.New(certFile, keyFile, zap.NewNop().Sugar(),
+		tlsconfig.ClientCA(caFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv, err := New(ctx, newProvider(t), zap.NewNop().Sugar(),
+		DisableInstrumentation(), TLSConfig(certs.Config()), RequireClientCert())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+
+	dial := func(c *tls.Config) pb.StonksClient {
+		conn, err := grpc.DialContext(ctx, "bufconn",
+			grpc.WithContextDialer(
+				func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				},
+			),
+			grpc.WithTransportCredentials(credentials.NewTLS(c)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+
+		return pb.NewStonksClient(conn)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dial(&tls.Config{RootCAs: roots, ServerName: "127.0.0.1",
+		Certificates: []tls.Certificate{pair}}).
+		ListSymbols(ctx, &pb.ListSymbolsRequest{})
+	if err != nil {
+		t.Errorf("with certificate: %v", err)
+	}
+
+	_, err = dial(&tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}).
+		ListSymbols(ctx, &pb.ListSymbolsRequest{})
+	if actual := status.Code(err); actual != codes.Unauthenticated {
+		t.Errorf("without certificate: actual: %s; expected: %s", actual,
+			codes.Unauthenticated)
+	}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue writes a certificate for 127.0.0.1, valid for servers and clients,
+// and its key to the files.
+func (ca *testCA) issue(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth,
+		},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
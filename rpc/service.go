@@ -0,0 +1,193 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+	"github.com/cry0genic/go-stocks/history"
+	"github.com/cry0genic/go-stocks/rpc/pb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// validSymbol matches the symbols the REST API accepts.
+var validSymbol = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// maxSymbols is the most symbols a request may name, duplicates included,
+// as in the REST API.
+const maxSymbols = 100
+
+var _ pb.StonksServer = (*service)(nil)
+
+type service struct {
+	pb.UnimplementedStonksServer
+
+	provider       history.Provider
+	log            *zap.SugaredLogger
+	maxLast        int
+	streamInterval time.Duration
+	symbols        *finance.Watchlist
+}
+
+func (s *service) GetQuote(ctx context.Context, req *pb.GetQuoteRequest) (
+	*pb.GetQuoteResponse, error) {
+	symbols, err := s.parseSymbols([]string{req.GetSymbol()})
+	if err != nil {
+		return nil, err
+	}
+	last, err := s.parseLast(req.GetLast())
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := s.provider.GetQuotes(ctx, symbols[0], last)
+	if err != nil {
+		return nil, s.error(err)
+	}
+
+	return &pb.GetQuoteResponse{Quotes: toProto(quotes)}, nil
+}
+
+func (s *service) GetQuotesBatch(ctx context.Context,
+	req *pb.GetQuotesBatchRequest) (*pb.GetQuotesBatchResponse, error) {
+	symbols, err := s.parseSymbols(req.GetSymbols())
+	if err != nil {
+		return nil, err
+	}
+	last, err := s.parseLast(req.GetLast())
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := s.provider.GetQuotesBatch(ctx, symbols, last)
+	if err != nil && !errors.Is(err, history.ErrNotFound) {
+		return nil, s.error(err)
+	}
+
+	resp := &pb.GetQuotesBatchResponse{Quotes: make(map[string]*pb.Quotes)}
+	for _, symbol := range symbols {
+		quotes, ok := batch[symbol]
+		if !ok {
+			resp.NotFound = append(resp.NotFound, symbol)
+			continue
+		}
+		resp.Quotes[symbol] = &pb.Quotes{Quotes: toProto(quotes)}
+	}
+
+	return resp, nil
+}
+
+func (s *service) StreamQuotes(req *pb.StreamQuotesRequest,
+	stream pb.Stonks_StreamQuotesServer) error {
+	symbols, err := s.parseSymbols(req.GetSymbols())
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	sent := make(map[string]time.Time, len(symbols))
+	t := time.NewTicker(s.streamInterval)
+	defer t.Stop()
+
+	for {
+		batch, err := s.provider.GetQuotesBatch(ctx, symbols, 1)
+		if err != nil && !errors.Is(err, history.ErrNotFound) {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return s.error(err)
+		}
+
+		for _, symbol := range symbols {
+			quotes := batch[symbol]
+			if len(quotes) == 0 || !quotes[0].Time.After(sent[symbol]) {
+				continue
+			}
+			if err = stream.Send(toProto(quotes[:1])[0]); err != nil {
+				return err
+			}
+			sent[symbol] = quotes[0].Time
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (s *service) ListSymbols(context.Context, *pb.ListSymbolsRequest) (
+	*pb.ListSymbolsResponse, error) {
+	return &pb.ListSymbolsResponse{Symbols: s.symbols.Symbols()}, nil
+}
+
+// error maps err to a gRPC status. Errors without a mapping are logged and
+// reported as internal errors without detail.
+func (s *service) error(err error) error {
+	if errors.Is(err, history.ErrNotFound) {
+		return status.Error(codes.NotFound, "no quotes found")
+	}
+
+	s.log.Error(err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func (s *service) parseLast(last int32) (int, error) {
+	if last < 0 || int(last) > s.maxLast {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"last must be between 0 and %d", s.maxLast)
+	}
+
+	return int(last), nil
+}
+
+// parseSymbols returns the unique, lowercase symbols, or the tracked symbols
+// if symbols is empty.
+func (s *service) parseSymbols(symbols []string) ([]string, error) {
+	if len(symbols) == 0 {
+		return s.symbols.Symbols(), nil
+	}
+	if len(symbols) > maxSymbols {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"more than %d symbols", maxSymbols)
+	}
+
+	var (
+		lc   = make([]string, 0, len(symbols))
+		seen = make(map[string]struct{})
+	)
+	for _, symbol := range symbols {
+		symbol = strings.ToLower(strings.TrimSpace(symbol))
+		if !validSymbol.MatchString(symbol) {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid symbol %q", symbol)
+		}
+		if _, ok := seen[symbol]; ok {
+			continue
+		}
+		seen[symbol] = struct{}{}
+		lc = append(lc, symbol)
+	}
+
+	return lc, nil
+}
+
+func toProto(quotes []finance.Quote) []*pb.Quote {
+	pbq := make([]*pb.Quote, len(quotes))
+	for i, q := range quotes {
+		pbq[i] = &pb.Quote{
+			Symbol: q.Symbol,
+			Price:  q.Price,
+			Time:   timestamppb.New(q.Time),
+		}
+	}
+
+	return pbq
+}
@@ -0,0 +1,39 @@
+package tlsconfig
+
+import "time"
+
+type Option func(*Reloader)
+
+// CipherSuites limits TLS 1.0–1.2 handshakes to the cipher suites. Nil uses
+// the crypto/tls defaults.
+func CipherSuites(ids []uint16) Option {
+	return func(r *Reloader) {
+		r.cipherSuites = ids
+	}
+}
+
+// ClientCA verifies the certificates clients present against the PEM
+// encoded CAs in the file. Clients without a certificate still connect, so
+// health checks work; servers refuse them elsewhere using Verified.
+func ClientCA(file string) Option {
+	return func(r *Reloader) {
+		r.clientCAFile = file
+	}
+}
+
+// MinVersion sets the lowest TLS version accepted, TLS 1.2 by default.
+func MinVersion(v uint16) Option {
+	return func(r *Reloader) {
+		if v != 0 {
+			r.minVersion = v
+		}
+	}
+}
+
+func WatchInterval(d time.Duration) Option {
+	return func(r *Reloader) {
+		if d > 0 {
+			r.watchInterval = d
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package tlsconfig builds server TLS configurations whose certificate and
+// client CAs reload from disk while the server runs.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultWatchInterval is how often Watch checks the files for changes.
+const DefaultWatchInterval = 10 * time.Second
+
+// Reloader serves a certificate and private key pair, and optionally the
+// client CAs verifying client certificates, read from files. Reloading them
+// affects new handshakes only, so open connections carry on undisturbed.
+type Reloader struct {
+	log           *zap.SugaredLogger
+	certFile      string
+	keyFile       string
+	clientCAFile  string
+	minVersion    uint16
+	cipherSuites  []uint16
+	watchInterval time.Duration
+
+	mu      sync.RWMutex
+	current *tls.Config          // the configuration of new handshakes
+	stats   map[string]fileStamp // the files as of the last reload
+}
+
+// fileStamp identifies a version of a file well enough to notice it changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Config returns a server configuration using the reloader's current
+// certificate and client CAs for each handshake. Like http.Server's own
+// configuration, it negotiates HTTP/2.
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.config().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}
+}
+
+// RequiresClientCert reports whether the reloader has client CAs, so
+// servers must require a client certificate where they serve anything but
+// health checks.
+func (r *Reloader) RequiresClientCert() bool {
+	return r.clientCAFile != ""
+}
+
+// Verified reports whether the client presented a certificate that one of
+// the client CAs signed.
+func Verified(s *tls.ConnectionState) bool {
+	return s != nil && len(s.VerifiedChains) > 0
+}
+
+func (r *Reloader) config() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current
+}
+
+// Reload reads the files again. If any of them is invalid, it returns an
+// error and keeps serving the previous certificate and client CAs.
+func (r *Reloader) Reload() error {
+	stats := make(map[string]fileStamp)
+	for _, name := range r.files() {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		stats[name] = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		CipherSuites: r.cipherSuites,
+		MinVersion:   r.minVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CAs: %w", err)
+		}
+		c.ClientCAs = x509.NewCertPool()
+		if !c.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("loading client CAs: no certificates in %s",
+				r.clientCAFile)
+		}
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	r.mu.Lock()
+	r.current = c
+	r.stats = stats
+	r.mu.Unlock()
+
+	r.log.Infof("loaded certificate %q, expiring %s", cert.Leaf.Subject.CommonName,
+		cert.Leaf.NotAfter.Format(time.RFC3339))
+
+	return nil
+}
+
+// Watch reloads the files whenever they change until ctx is canceled. It
+// polls rather than watching for file system events, which miss the renamed
+// directories and symlinks certificates are often rotated with. A failed
+// reload is retried once the files change again.
+func (r *Reloader) Watch(ctx context.Context) {
+	t := time.NewTicker(r.watchInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			r.log.Errorf("reloading certificate: %v; keeping the current one", err)
+		}
+	}
+}
+
+// changed returns true if any file differs from its last reloaded version.
+// A file that's missing, perhaps mid-rotation, hasn't changed yet.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, name := range r.files() {
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		if (fileStamp{modTime: fi.ModTime(), size: fi.Size()}) != r.stats[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+
+	return files
+}
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion returns the TLS version named "1.0", "1.1", "1.2" or "1.3".
+func ParseVersion(name string) (uint16, error) {
+	v, ok := versions[strings.TrimPrefix(strings.ToLower(name), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q; use 1.0, 1.1, 1.2 or 1.3",
+			name)
+	}
+
+	return v, nil
+}
+
+// ParseCipherSuites returns the IDs of the cipher suites named as in the
+// crypto/tls constants, such as TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256.
+// It rejects the suites crypto/tls considers insecure. TLS 1.3 suites
+// aren't configurable.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := suites[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// New returns a reloader serving the certificate and private key in the PEM
+// encoded files. It returns an error if it can't load them.
+func New(certFile, keyFile string, log *zap.SugaredLogger,
+	options ...Option) (*Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TLS needs both a certificate and a private key")
+	}
+
+	r := &Reloader{
+		log:           log.Named("tls"),
+		certFile:      certFile,
+		keyFile:       keyFile,
+		minVersion:    tls.VersionTLS12,
+		watchInterval: DefaultWatchInterval,
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(r)
+		}
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
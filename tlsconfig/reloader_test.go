@@ -0,0 +1,381 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestReloaderReload(t *testing.T) {
+	t.Parallel()
+
+	ca := newCA(t, "test CA")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca.issue(t, "first", certFile, keyFile)
+
+	r, err := New(certFile, keyFile, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(r)
+	defer srv.Close()
+
+	open := ca.client(nil)
+	if actual := served(t, open, srv.URL); actual != "first" {
+		t.Errorf("actual: %q; expected: %q", actual, "first")
+	}
+
+	ca.issue(t, "second", certFile, keyFile)
+	if err = r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The open connection carries on with the certificate it negotiated.
+	if actual := served(t, open, srv.URL); actual != "first" {
+		t.Errorf("open connection: actual: %q; expected: %q", actual, "first")
+	}
+	if actual := served(t, ca.client(nil), srv.URL); actual != "second" {
+		t.Errorf("new connection: actual: %q; expected: %q", actual, "second")
+	}
+
+	// A key that doesn't match the certificate keeps the current pair.
+	newCA(t, "other CA").issue(t, "third", filepath.Join(dir, "other.pem"), keyFile)
+	if err = r.Reload(); err == nil {
+		t.Error("expected an error for a mismatched key")
+	}
+	if actual := served(t, ca.client(nil), srv.URL); actual != "second" {
+		t.Errorf("after failed reload: actual: %q; expected: %q", actual, "second")
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	t.Parallel()
+
+	ca := newCA(t, "test CA")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca.issue(t, "first", certFile, keyFile)
+
+	r, err := New(certFile, keyFile, zap.NewNop().Sugar(),
+		WatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx)
+
+	srv := newServer(r)
+	defer srv.Close()
+
+	ca.issue(t, "second", certFile, keyFile)
+	// Make sure the files look changed even on coarse-grained file systems.
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{certFile, keyFile} {
+		if err = os.Chtimes(name, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		actual := served(t, ca.client(nil), srv.URL)
+		if actual == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("actual: %q; expected: %q", actual, "second")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloaderClientCA(t *testing.T) {
+	t.Parallel()
+
+	ca := newCA(t, "test CA")
+	clientCA := newCA(t, "client CA")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca.issue(t, "server", certFile, keyFile)
+	caFile := filepath.Join(dir, "client-ca.pem")
+	if err := os.WriteFile(caFile, clientCA.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(certFile, keyFile, zap.NewNop().Sugar(), ClientCA(caFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.RequiresClientCert() {
+		t.Error("expected the reloader to require client certificates")
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(strconv.FormatBool(Verified(r.TLS))))
+		}))
+	srv.TLS = r.Config()
+	srv.StartTLS()
+	defer srv.Close()
+
+	verified := func(client *http.Client) string {
+		t.Helper()
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	clientCert, clientKey := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	clientCA.issue(t, "client", clientCert, clientKey)
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := verified(ca.client(&pair)); actual != "true" {
+		t.Errorf("actual: %q; expected: %q", actual, "true")
+	}
+
+	// Clients without a certificate connect, say for health checks, but
+	// aren't verified.
+	if actual := verified(ca.client(nil)); actual != "false" {
+		t.Errorf("no certificate: actual: %q; expected: %q", actual, "false")
+	}
+
+	// Nor are clients with a certificate the client CA didn't sign.
+	ca.issue(t, "stranger", clientCert, clientKey)
+	pair, err = tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := verified(ca.client(&pair)); actual != "false" {
+		t.Errorf("other CA: actual: %q; expected: %q", actual, "false")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	newCA(t, "test CA").issue(t, "server", certFile, keyFile)
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		cert, key string
+		options   []Option
+	}{
+		{cert: certFile},
+		{cert: certFile, key: filepath.Join(dir, "missing.pem")},
+		{cert: certFile, key: keyFile, options: []Option{ClientCA(empty)}},
+	}
+
+	for i, tc := range testCases {
+		_, err := New(tc.cert, tc.key, zap.NewNop().Sugar(), tc.options...)
+		if err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+		t.Logf("%d: %v", i, err)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		expected uint16
+		err      bool
+	}{
+		{name: "1.2", expected: tls.VersionTLS12},
+		{name: "TLS1.3", expected: tls.VersionTLS13},
+		{name: "1.4", err: true},
+		{name: "", err: true},
+	}
+
+	for i, tc := range testCases {
+		actual, err := ParseVersion(tc.name)
+		if (err != nil) != tc.err {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%d: actual: %#x; expected: %#x", i, actual, tc.expected)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	t.Parallel()
+
+	actual, err := ParseCipherSuites([]string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		" tls_ecdhe_rsa_with_chacha20_poly1305_sha256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual: %#v; expected: %#v", actual, expected)
+	}
+
+	for _, name := range []string{"TLS_RSA_WITH_RC4_128_SHA", "TLS_NOPE"} {
+		if _, err = ParseCipherSuites([]string{name}); err == nil {
+			t.Errorf("expected an error for %s", name)
+		}
+	}
+}
+
+// newServer returns a TLS server using r's configuration.
+func newServer(r *Reloader) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+	srv.TLS = r.Config()
+	srv.StartTLS()
+
+	return srv
+}
+
+// served returns the common name of the certificate the server at url
+// presented on the connection client used.
+func served(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	return resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue writes a certificate for 127.0.0.1 with the common name, valid for
+// servers and clients, and its key to the files.
+func (ca *testCA) issue(t *testing.T, name, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth,
+		},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// client returns a client trusting the CA and presenting cert, if any.
+func (ca *testCA) client(cert *tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	c := &tls.Config{RootCAs: pool}
+	if cert != nil {
+		c.Certificates = []tls.Certificate{*cert}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: c}}
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import "io"
+
+type Option func(*Provider)
+
+// Endpoint sets the OTLP/HTTP collector URL, e.g. "http://localhost:4318".
+// An http scheme disables TLS.
+func Endpoint(u string) Option {
+	return func(p *Provider) {
+		if u != "" {
+			p.endpoint = u
+		}
+	}
+}
+
+// SampleRatio sets the fraction of traces sampled, from 0 to 1. Requests
+// continuing a trace follow the caller's sampling decision.
+func SampleRatio(f float64) Option {
+	return func(p *Provider) {
+		if f >= 0 && f <= 1 {
+			p.sampleRatio = f
+		}
+	}
+}
+
+func ServiceVersion(v string) Option {
+	return func(p *Provider) {
+		if v != "" {
+			p.version = v
+		}
+	}
+}
+
+// Writer sets where the stdout exporter writes spans.
+func Writer(w io.Writer) Option {
+	return func(p *Provider) {
+		if w != nil {
+			p.writer = w
+		}
+	}
+}
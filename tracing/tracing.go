@@ -0,0 +1,143 @@
+// Package tracing exports OpenTelemetry traces from the packages that start
+// spans with the global tracer provider.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultEndpoint = "http://localhost:4318"
+
+	DefaultSampleRatio = 1.0
+
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var ErrUnknownExporter = fmt.Errorf("unknown exporter")
+
+// Provider exports the spans started with the global tracer provider.
+type Provider struct {
+	endpoint    string
+	sampleRatio float64
+	version     string
+	writer      io.Writer
+
+	tp *sdktrace.TracerProvider
+}
+
+// Enabled is true if spans are exported.
+func (p *Provider) Enabled() bool {
+	return p.tp != nil
+}
+
+// Shutdown exports any remaining spans.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+
+	return p.tp.Shutdown(ctx)
+}
+
+func (p *Provider) exporter(ctx context.Context, exporter string) (
+	sdktrace.SpanExporter, error) {
+	switch strings.ToLower(exporter) {
+	case ExporterOTLP:
+		u, err := url.Parse(p.endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q", p.endpoint)
+		}
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+		if u.Scheme == "http" {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		if u.Path != "" && u.Path != "/" {
+			options = append(options, otlptracehttp.WithURLPath(u.Path))
+		}
+		return otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(p.writer))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, exporter)
+	}
+}
+
+// New exports spans to exporter: ExporterOTLP, ExporterStdout, or
+// ExporterNone, which leaves tracing disabled. Other exporters install the
+// global tracer provider and W3C trace context propagation.
+func New(ctx context.Context, exporter string, options ...Option) (
+	*Provider, error) {
+	p := &Provider{
+		endpoint:    DefaultEndpoint,
+		sampleRatio: DefaultSampleRatio,
+		version:     "dev",
+		writer:      os.Stdout,
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(p)
+		}
+	}
+
+	if exporter == "" || strings.EqualFold(exporter, ExporterNone) {
+		return p, nil
+	}
+
+	exp, err := p.exporter(ctx, exporter)
+	if err != nil {
+		return nil, err
+	}
+
+	p.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String("stonks"),
+			semconv.ServiceVersionKey.String(p.version),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(p.sampleRatio))),
+	)
+	otel.SetTracerProvider(p.tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	return p, nil
+}
+
+// End ends span, marking it failed if err isn't nil or one of the expected
+// errors, such as a provider's not found error.
+func End(span trace.Span, err error, expected ...error) {
+	defer span.End()
+
+	if err == nil {
+		return
+	}
+	for _, e := range expected {
+		if errors.Is(err, e) {
+			return
+		}
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	p, err := New(ctx, ExporterNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Enabled() {
+		t.Error("expected tracing to be disabled")
+	}
+	if err = p.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = New(ctx, "zipkin"); !errors.Is(err, ErrUnknownExporter) {
+		t.Errorf("expected ErrUnknownExporter: %v", err)
+	}
+	if _, err = New(ctx, ExporterOTLP, Endpoint("localhost")); err == nil {
+		t.Error("expected an invalid endpoint error")
+	}
+}
+
+// TestStdout isn't parallel since New sets the global tracer provider.
+func TestStdout(t *testing.T) {
+	ctx := context.Background()
+	buf := new(bytes.Buffer)
+
+	p, err := New(ctx, ExporterStdout, Writer(buf), ServiceVersion("1.2.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Enabled() {
+		t.Fatal("expected tracing to be enabled")
+	}
+
+	_, span := otel.Tracer("test").Start(ctx, "test.span")
+	End(span, errors.New("boom"))
+
+	if err = p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{`"Name":"test.span"`, `"Code":"Error"`, "1.2.3"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in exported spans", expected)
+		}
+	}
+	if t.Failed() {
+		t.Logf("actual: %s", out)
+	}
+}
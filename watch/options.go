@@ -0,0 +1,83 @@
+package watch
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+type Option func(*Watcher)
+
+// ErrorOutput sets where refresh errors are written when not on a terminal.
+func ErrorOutput(out io.Writer) Option {
+	return func(w *Watcher) {
+		if out != nil {
+			w.errOut = out
+		}
+	}
+}
+
+// Filter shows only the symbols matching the path.Match pattern.
+func Filter(pattern string) Option {
+	return func(w *Watcher) {
+		w.filter = strings.ToLower(pattern)
+	}
+}
+
+// History sets the number of quotes per symbol read at start to find the
+// day's open.
+func History(n int) Option {
+	return func(w *Watcher) {
+		if n > 0 {
+			w.history = n
+		}
+	}
+}
+
+// Interval sets the duration between refreshes.
+func Interval(d time.Duration) Option {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.interval = d
+		}
+	}
+}
+
+// Sort orders the table by symbol, price, change or updated, descending if
+// prefixed with "-".
+func Sort(key string) Option {
+	return func(w *Watcher) {
+		if key != "" {
+			w.sort = strings.ToLower(key)
+		}
+	}
+}
+
+// Symbols sets the symbols watched. By default, the source's.
+func Symbols(symbols []string) Option {
+	return func(w *Watcher) {
+		w.symbols = make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			if symbol = strings.ToLower(strings.TrimSpace(symbol)); symbol != "" {
+				w.symbols = append(w.symbols, symbol)
+			}
+		}
+	}
+}
+
+// Terminal redraws a table on each refresh rather than writing a line per
+// new quote.
+func Terminal(tty bool) Option {
+	return func(w *Watcher) {
+		w.tty = tty
+	}
+}
+
+// Ticks sets the number of recent quotes plotted per symbol.
+func Ticks(n int) Option {
+	return func(w *Watcher) {
+		if n > 0 {
+			w.ticks = n
+		}
+	}
+}
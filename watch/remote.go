@@ -0,0 +1,99 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+var _ Source = (*Remote)(nil)
+
+// Remote reads quotes from a stonks API server.
+type Remote struct {
+	apiKey     string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// GetQuotesBatch returns the last quotes of each symbol from the server's
+// /v1/stocks resource, or of the server's symbols if symbols is empty.
+func (r *Remote) GetQuotesBatch(ctx context.Context, symbols []string,
+	last int) (finance.QuoteBatch, error) {
+	v := url.Values{}
+	v.Set("last", strconv.Itoa(last))
+	if len(symbols) > 0 {
+		v.Set("symbols", strings.Join(symbols, ","))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		r.endpoint+"?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("X-API-Key", r.apiKey)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		var p struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&p)
+		if p.Detail == "" {
+			p.Detail = p.Title
+		}
+		if p.Detail == "" {
+			p.Detail = http.StatusText(resp.StatusCode)
+		}
+		return nil, fmt.Errorf("%s: %d %s", r.endpoint, resp.StatusCode,
+			p.Detail)
+	}
+
+	var body struct {
+		Quotes finance.QuoteBatch `json:"quotes"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return body.Quotes, nil
+}
+
+// NewRemote returns a source reading from the stonks API server at baseURL,
+// such as http://localhost:18081, authenticating with apiKey if it isn't
+// empty.
+func NewRemote(baseURL, apiKey string) (*Remote, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("API URL %q: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("API URL %q: must be an http or https URL",
+			baseURL)
+	}
+
+	return &Remote{
+		apiKey:     apiKey,
+		endpoint:   strings.TrimSuffix(u.String(), "/") + "/v1/stocks",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
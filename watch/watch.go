@@ -0,0 +1,381 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+const (
+	// DefaultHistory is the number of quotes per symbol read at start to
+	// find the day's open: a day of quotes polled each minute.
+	DefaultHistory = 1440
+
+	DefaultInterval = time.Second
+
+	DefaultSort = "symbol"
+
+	DefaultTicks = 30
+)
+
+// Source returns the last quotes of each symbol, newest first. An empty
+// symbols list means the source's default symbols. history.Provider
+// implementations, such as the SQLite client, and Remote are sources.
+type Source interface {
+	GetQuotesBatch(ctx context.Context, symbols []string, last int) (
+		finance.QuoteBatch, error)
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Watcher shows the latest quotes of its symbols as they arrive. On a
+// terminal, it redraws a table of them on each refresh; otherwise, it writes
+// a line per new quote.
+type Watcher struct {
+	source   Source
+	out      io.Writer
+	errOut   io.Writer
+	filter   string
+	history  int
+	interval time.Duration
+	sort     string
+	symbols  []string
+	ticks    int
+	tty      bool
+
+	now  func() time.Time
+	rows map[string]*row
+	err  error // the last refresh's error, shown on the terminal
+}
+
+type row struct {
+	open  finance.Quote   // the first quote of the last quote's day
+	ticks []finance.Quote // the recent quotes, oldest first
+}
+
+func (r *row) last() finance.Quote {
+	return r.ticks[len(r.ticks)-1]
+}
+
+// Run shows quotes until ctx is canceled. It returns an error if it can't
+// read the initial quotes; later errors are reported and retried on the next
+// refresh.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.refresh(ctx, w.history); err != nil {
+		return err
+	}
+	if w.tty {
+		fmt.Fprint(w.out, "\x1b[2J\x1b[?25l") // clear the screen, hide the cursor
+		defer fmt.Fprint(w.out, "\x1b[?25h")
+	}
+	w.render()
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+
+		err := w.refresh(ctx, w.ticks)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !w.tty {
+			fmt.Fprintf(w.errOut, "refreshing quotes: %v\n", err)
+		}
+		w.render()
+	}
+}
+
+// refresh reads the last quotes of each symbol and merges the new ones.
+func (w *Watcher) refresh(ctx context.Context, last int) error {
+	batch, err := w.source.GetQuotesBatch(ctx, w.symbols, last)
+	w.err = err
+	if err != nil {
+		return err
+	}
+
+	fresh := make(map[string][]finance.Quote)
+	for symbol, quotes := range batch {
+		if !w.match(symbol) {
+			continue
+		}
+		r, ok := w.rows[symbol]
+		if !ok {
+			r = new(row)
+		}
+		added := r.merge(quotes, w.ticks)
+		if len(added) == 0 {
+			continue
+		}
+		if !ok {
+			w.rows[symbol] = r
+			added = added[len(added)-1:] // only the latest of a new symbol
+		}
+		fresh[symbol] = added
+	}
+
+	if !w.tty {
+		w.printQuotes(fresh)
+	}
+
+	return nil
+}
+
+func (w *Watcher) match(symbol string) bool {
+	if w.filter == "" {
+		return true
+	}
+	ok, _ := path.Match(w.filter, symbol)
+
+	return ok
+}
+
+// merge adds the quotes newer than the row's last quote, keeping at most
+// ticks of them, and returns the ones added, oldest first.
+func (r *row) merge(quotes []finance.Quote, ticks int) []finance.Quote {
+	var added []finance.Quote
+	for i := len(quotes) - 1; i >= 0; i-- {
+		q := quotes[i]
+		if len(r.ticks) > 0 && !q.Time.After(r.last().Time) {
+			continue
+		}
+		if r.open.Time.IsZero() || !sameDay(q.Time, r.open.Time) {
+			r.open = q
+		}
+		r.ticks = append(r.ticks, q)
+		added = append(added, q)
+	}
+	if len(r.ticks) > ticks {
+		r.ticks = append(r.ticks[:0], r.ticks[len(r.ticks)-ticks:]...)
+	}
+
+	return added
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+
+	return ay == by && am == bm && ad == bd
+}
+
+func (w *Watcher) render() {
+	if w.tty {
+		w.draw()
+	}
+}
+
+// printQuotes writes a line per quote, oldest first. The quotes are keyed by
+// their row's symbol, which may differ in case from their own.
+func (w *Watcher) printQuotes(quotes map[string][]finance.Quote) {
+	type line struct {
+		symbol string
+		quote  finance.Quote
+	}
+	var lines []line
+	for symbol, qs := range quotes {
+		for _, q := range qs {
+			lines = append(lines, line{symbol: symbol, quote: q})
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].quote.Time.Equal(lines[j].quote.Time) {
+			return lines[i].symbol < lines[j].symbol
+		}
+		return lines[i].quote.Time.Before(lines[j].quote.Time)
+	})
+
+	for _, l := range lines {
+		diff, pct := w.rows[l.symbol].change(l.quote)
+		fmt.Fprintf(w.out, "%s %s %s %s\n",
+			l.quote.Time.UTC().Format(time.RFC3339), l.symbol,
+			formatPrice(l.quote.Price), formatChange(diff, pct))
+	}
+}
+
+// change returns the price change from the open to q, and its percentage.
+func (r *row) change(q finance.Quote) (float64, float64) {
+	diff := q.Price - r.open.Price
+	if r.open.Price == 0 || !sameDay(q.Time, r.open.Time) {
+		return diff, 0
+	}
+
+	return diff, 100 * diff / r.open.Price
+}
+
+// draw redraws the table from the top left of the terminal, clearing what's
+// left of the previous frame.
+func (w *Watcher) draw() {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+
+	now := w.now()
+	status := now.Format("15:04:05")
+	if w.err != nil {
+		status += "  error: " + w.err.Error()
+	}
+	b.WriteString(status + "\x1b[K\n\x1b[K\n")
+
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SYMBOL\tPRICE\tCHANGE\tTREND\tUPDATED")
+	for _, symbol := range w.sorted() {
+		r := w.rows[symbol]
+		diff, pct := r.change(r.last())
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", symbol,
+			formatPrice(r.last().Price), formatChange(diff, pct),
+			sparkline(r.ticks), formatAge(now.Sub(r.last().Time)))
+	}
+	_ = tw.Flush()
+	b.WriteString(strings.ReplaceAll(table.String(), "\n", "\x1b[K\n"))
+
+	b.WriteString("\x1b[J")
+	fmt.Fprint(w.out, b.String())
+}
+
+// sorted returns the symbols in the watcher's sort order. A sort key
+// prefixed with "-" sorts in descending order.
+func (w *Watcher) sorted() []string {
+	symbols := make([]string, 0, len(w.rows))
+	for symbol := range w.rows {
+		symbols = append(symbols, symbol)
+	}
+
+	key := strings.TrimPrefix(w.sort, "-")
+	desc := key != w.sort
+	less := func(i, j int) bool {
+		a, b := w.rows[symbols[i]], w.rows[symbols[j]]
+		switch key {
+		case "price":
+			if a.last().Price != b.last().Price {
+				return a.last().Price < b.last().Price
+			}
+		case "change":
+			_, ap := a.change(a.last())
+			_, bp := b.change(b.last())
+			if ap != bp {
+				return ap < bp
+			}
+		case "updated":
+			if !a.last().Time.Equal(b.last().Time) {
+				return a.last().Time.Before(b.last().Time)
+			}
+		}
+		return symbols[i] < symbols[j]
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+
+	return symbols
+}
+
+// sparkline plots the quotes' prices, scaled between the lowest and highest.
+func sparkline(quotes []finance.Quote) string {
+	if len(quotes) == 0 {
+		return ""
+	}
+
+	min, max := quotes[0].Price, quotes[0].Price
+	for _, q := range quotes {
+		if q.Price < min {
+			min = q.Price
+		}
+		if q.Price > max {
+			max = q.Price
+		}
+	}
+
+	line := make([]rune, len(quotes))
+	for i, q := range quotes {
+		level := len(sparks) / 2
+		if max > min {
+			level = int((q.Price - min) / (max - min) * float64(len(sparks)-1))
+		}
+		line[i] = sparks[level]
+	}
+
+	return string(line)
+}
+
+func formatPrice(p float64) string {
+	return strconv.FormatFloat(p, 'f', 2, 64)
+}
+
+func formatChange(diff, pct float64) string {
+	return fmt.Sprintf("%+.2f (%+.2f%%)", diff, pct)
+}
+
+// formatAge returns d rounded for display: seconds under a minute, minutes
+// under an hour, and hours beyond.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return "now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", d/time.Second)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", d/time.Minute)
+	default:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	}
+}
+
+func New(source Source, out io.Writer, options ...Option) (*Watcher, error) {
+	if source == nil {
+		return nil, fmt.Errorf("source cannot be nil")
+	}
+	if out == nil {
+		return nil, fmt.Errorf("output cannot be nil")
+	}
+
+	w := &Watcher{
+		source:   source,
+		out:      out,
+		errOut:   os.Stderr,
+		history:  DefaultHistory,
+		interval: DefaultInterval,
+		sort:     DefaultSort,
+		ticks:    DefaultTicks,
+		now:      time.Now,
+		rows:     make(map[string]*row),
+	}
+
+	for _, option := range options {
+		if option != nil {
+			option(w)
+		}
+	}
+
+	switch strings.TrimPrefix(w.sort, "-") {
+	case "symbol", "price", "change", "updated":
+	default:
+		return nil, fmt.Errorf("unknown sort key %q; use symbol, price, "+
+			"change or updated", w.sort)
+	}
+	if _, err := path.Match(w.filter, ""); err != nil {
+		return nil, fmt.Errorf("filter %q: %w", w.filter, err)
+	}
+	if w.history < w.ticks {
+		w.history = w.ticks
+	}
+
+	return w, nil
+}
@@ -0,0 +1,255 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cry0genic/go-stocks/finance"
+)
+
+var open = time.Date(2021, 5, 21, 13, 30, 0, 0, time.UTC)
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		prices   []float64
+		expected string
+	}{
+		{prices: nil, expected: ""},
+		{prices: []float64{10}, expected: "▅"},
+		{prices: []float64{10, 10, 10}, expected: "▅▅▅"},
+		{prices: []float64{1, 2, 3, 4, 5, 6, 7, 8}, expected: "▁▂▃▄▅▆▇█"},
+		{prices: []float64{8, 1, 8}, expected: "█▁█"},
+	}
+
+	for i, tc := range testCases {
+		quotes := make([]finance.Quote, len(tc.prices))
+		for j, p := range tc.prices {
+			quotes[j] = finance.Quote{Price: p}
+		}
+		if actual := sparkline(quotes); actual != tc.expected {
+			t.Errorf("%d: actual: %q; expected: %q", i, actual, tc.expected)
+		}
+	}
+}
+
+func TestWatcherLines(t *testing.T) {
+	t.Parallel()
+
+	src := &batchSource{batches: []finance.QuoteBatch{
+		{
+			"fb": {
+				{Price: 110, Symbol: "fb", Time: open.Add(time.Minute)},
+				{Price: 100, Symbol: "fb", Time: open},
+				{Price: 90, Symbol: "fb", Time: open.Add(-24 * time.Hour)},
+			},
+			"aapl": {{Price: 50, Symbol: "aapl", Time: open}},
+		},
+		{
+			"fb": {
+				{Price: 95, Symbol: "fb", Time: open.Add(2 * time.Minute)},
+				{Price: 110, Symbol: "fb", Time: open.Add(time.Minute)},
+			},
+			"aapl": {{Price: 50, Symbol: "aapl", Time: open}},
+		},
+	}}
+	out := new(bytes.Buffer)
+	w, err := New(src, out, Symbols([]string{"FB", "aapl"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(src.batches); i++ {
+		if err = w.refresh(context.Background(), w.history); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new symbol prints only its latest quote; later refreshes print
+	// each new quote.
+	expected := strings.Join([]string{
+		"2021-05-21T13:30:00Z aapl 50.00 +0.00 (+0.00%)",
+		"2021-05-21T13:31:00Z fb 110.00 +10.00 (+10.00%)",
+		"2021-05-21T13:32:00Z fb 95.00 -5.00 (-5.00%)",
+	}, "\n") + "\n"
+	if actual := out.String(); actual != expected {
+		t.Errorf("actual:\n%s\nexpected:\n%s", actual, expected)
+	}
+	if !reflect.DeepEqual(src.symbols, []string{"fb", "aapl"}) {
+		t.Errorf("actual symbols requested: %v", src.symbols)
+	}
+}
+
+func TestWatcherLinesMixedCase(t *testing.T) {
+	t.Parallel()
+
+	// Quotes keep IEX Cloud's uppercase symbols in some providers.
+	src := &batchSource{batches: []finance.QuoteBatch{{
+		"fb": {{Price: 100, Symbol: "FB", Time: open}},
+		"AAPL": {
+			{Price: 55, Symbol: "aapl", Time: open.Add(time.Minute)},
+			{Price: 50, Symbol: "aapl", Time: open},
+		},
+	}}}
+	out := new(bytes.Buffer)
+	w, err := New(src, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.refresh(context.Background(), w.history); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "2021-05-21T13:30:00Z fb 100.00 +0.00 (+0.00%)\n" +
+		"2021-05-21T13:31:00Z AAPL 55.00 +5.00 (+10.00%)\n"
+	if actual := out.String(); actual != expected {
+		t.Errorf("actual:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestWatcherTable(t *testing.T) {
+	t.Parallel()
+
+	src := &batchSource{batches: []finance.QuoteBatch{{
+		"fb": {
+			{Price: 99, Symbol: "fb", Time: open.Add(time.Minute)},
+			{Price: 100, Symbol: "fb", Time: open},
+		},
+		"aapl": {
+			{Price: 55, Symbol: "aapl", Time: open.Add(2 * time.Minute)},
+			{Price: 50, Symbol: "aapl", Time: open},
+		},
+		"amzn": {{Price: 3000, Symbol: "amzn", Time: open}},
+	}}}
+
+	testCases := []struct {
+		options  []Option
+		expected []string
+	}{
+		{expected: []string{"aapl", "amzn", "fb"}},
+		{options: []Option{Sort("-change")}, expected: []string{"aapl", "amzn", "fb"}},
+		{options: []Option{Sort("change")}, expected: []string{"fb", "amzn", "aapl"}},
+		{options: []Option{Sort("-price")}, expected: []string{"amzn", "fb", "aapl"}},
+		{options: []Option{Sort("updated")}, expected: []string{"amzn", "fb", "aapl"}},
+		{options: []Option{Filter("A*")}, expected: []string{"aapl", "amzn"}},
+	}
+
+	for i, tc := range testCases {
+		src.next = 0
+		out := new(bytes.Buffer)
+		w, err := New(src, out, append(tc.options, Terminal(true))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.now = func() time.Time { return open.Add(3 * time.Minute) }
+		if err = w.refresh(context.Background(), w.history); err != nil {
+			t.Fatal(err)
+		}
+		w.draw()
+
+		var actual []string
+		lines := strings.Split(out.String(), "\n")
+		for _, line := range lines[3 : len(lines)-1] {
+			actual = append(actual, strings.Fields(line)[0])
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%d: actual: %v; expected: %v", i, actual, tc.expected)
+		}
+
+		if i == 0 {
+			expected := "aapl    55.00    +5.00 (+10.00%)  ▁█     1m ago\x1b[K"
+			if lines[3] != expected {
+				t.Errorf("actual row:\n%q\nexpected:\n%q", lines[3], expected)
+			}
+		}
+	}
+}
+
+func TestNewInvalidSort(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(&batchSource{}, new(bytes.Buffer), Sort("volume"))
+	if err == nil {
+		t.Error("expected an error for an unknown sort key")
+	}
+}
+
+func TestRemote(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "secret" {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"title":"Unauthorized","detail":"missing API key"}`))
+				return
+			}
+			if r.URL.Path != "/v1/stocks" ||
+				r.URL.Query().Get("symbols") != "fb,aapl" ||
+				r.URL.Query().Get("last") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"quotes":{"fb":[{"price":123.45,"symbol":"fb","time":"2021-05-21T13:30:00Z"}]},"not_found":["aapl"]}`))
+		}))
+	defer srv.Close()
+
+	r, err := NewRemote(srv.URL+"/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := r.GetQuotesBatch(context.Background(),
+		[]string{"fb", "aapl"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := finance.QuoteBatch{
+		"fb": {{Price: 123.45, Symbol: "fb", Time: open}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual: %#v; expected: %#v", actual, expected)
+	}
+
+	r, err = NewRemote(srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.GetQuotesBatch(context.Background(), nil, 1)
+	if err == nil || !strings.Contains(err.Error(), "401 missing API key") {
+		t.Errorf("actual error: %v; expected 401 missing API key", err)
+	}
+
+	if _, err = NewRemote("localhost:18081", ""); err == nil {
+		t.Error("expected an error for a URL without a scheme")
+	}
+}
+
+// batchSource returns its batches in turn, repeating the last one.
+type batchSource struct {
+	batches []finance.QuoteBatch
+	next    int
+	symbols []string
+}
+
+func (s *batchSource) GetQuotesBatch(_ context.Context, symbols []string,
+	_ int) (finance.QuoteBatch, error) {
+	s.symbols = symbols
+	if len(s.batches) == 0 {
+		return nil, nil
+	}
+
+	b := s.batches[s.next]
+	if s.next < len(s.batches)-1 {
+		s.next++
+	}
+
+	return b, nil
+}